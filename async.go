@@ -0,0 +1,213 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Asynchronous DMA transfers. Read and write requests are submitted to a queue
+// and processed by a pool of workers, one per device file descriptor. The
+// result of each request is delivered on a completion channel, so that
+// applications can overlap transfers with computation.
+//
+// Ordering: with a single device, requests are processed and complete in the
+// order they were submitted. With multiple devices, requests are processed in
+// parallel and may complete in any order; the request's Tag can be used to
+// match completions to requests.
+//
+
+package gopcie
+
+import (
+	"sync"
+)
+
+// PCIeDMARequest is an asynchronous DMA transfer request.
+type PCIeDMARequest struct {
+	Op   int         // PCIE_ACCESS_READ or PCIE_ACCESS_WRITE
+	Addr uint64      // card address
+	Data []byte      // data to be written or buffer for read data
+	Tag  interface{} // user data, returned unchanged with the completion
+}
+
+// PCIeDMACompletion reports the result of an asynchronous DMA transfer request.
+type PCIeDMACompletion struct {
+	Request     *PCIeDMARequest
+	Transferred int   // number of bytes transferred
+	Err         error // nil if the transfer succeeded
+}
+
+// PCIeDMAAsync submits DMA transfers asynchronously.
+type PCIeDMAAsync struct {
+	devs        []*PCIeDMA
+	ownsDevs    bool
+	requests    chan *PCIeDMARequest
+	completions chan PCIeDMACompletion
+	wg          sync.WaitGroup
+
+	// the mutex protects the request queue against being closed while
+	// requests are submitted
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// PCIeDMAAsyncOpen opens the DMA devices with the specified names and access
+// mode and creates an asynchronous submission queue with the specified depth.
+// The same device name may be specified multiple times to open several file
+// descriptors for the same device, which allows the driver to process multiple
+// transfers in parallel. The devices are closed when the queue is closed.
+func PCIeDMAAsyncOpen(devNames []string, accessMode,
+	queueDepth int) (*PCIeDMAAsync, error) {
+	if len(devNames) == 0 {
+		return nil, newPCIeError("open async dma", "", ErrInvalidArgument)
+	}
+
+	devs := make([]*PCIeDMA, 0, len(devNames))
+	for _, devName := range devNames {
+		dev, err := PCIeDMAOpen(devName, accessMode)
+		if err != nil {
+			for _, dev := range devs {
+				dev.Close()
+			}
+			return nil, err
+		}
+		devs = append(devs, dev)
+	}
+
+	async, err := NewPCIeDMAAsync(devs, queueDepth)
+	if err != nil {
+		return nil, err
+	}
+	async.ownsDevs = true
+	return async, nil
+}
+
+// NewPCIeDMAAsync creates an asynchronous submission queue with the specified
+// depth on top of already opened DMA devices. One worker is started per
+// device. The devices are not closed when the queue is closed. At least one
+// device must be specified, since otherwise no worker would process the
+// submitted requests.
+func NewPCIeDMAAsync(devs []*PCIeDMA, queueDepth int) (*PCIeDMAAsync, error) {
+	if len(devs) == 0 {
+		return nil, newPCIeError("create async dma", "", ErrInvalidArgument)
+	}
+	if queueDepth < 1 {
+		queueDepth = 1
+	}
+
+	async := &PCIeDMAAsync{
+		devs:        devs,
+		requests:    make(chan *PCIeDMARequest, queueDepth),
+		completions: make(chan PCIeDMACompletion, queueDepth),
+	}
+
+	// start one worker per device
+	for _, dev := range devs {
+		async.wg.Add(1)
+		go async.worker(dev)
+	}
+
+	// close completion channel once all workers are done
+	go func() {
+		async.wg.Wait()
+		close(async.completions)
+	}()
+
+	return async, nil
+}
+
+// Submit queues a transfer request. It blocks while the queue is full. The
+// request's data must not be accessed until its completion is received.
+func (async *PCIeDMAAsync) Submit(req *PCIeDMARequest) error {
+	if req.Op != PCIE_ACCESS_READ && req.Op != PCIE_ACCESS_WRITE {
+		return newPCIeError("submit dma request", "", ErrInvalidArgument)
+	}
+
+	async.mutex.RLock()
+	defer async.mutex.RUnlock()
+	if async.closed {
+		return newPCIeError("submit dma request", "", ErrClosed)
+	}
+	async.requests <- req
+	return nil
+}
+
+// Completions returns the channel on which the completions of submitted
+// requests are delivered. The channel must be drained, otherwise workers block
+// once it is full. It is closed after the queue has been closed and all
+// submitted requests have completed.
+func (async *PCIeDMAAsync) Completions() <-chan PCIeDMACompletion {
+	return async.completions
+}
+
+// Close stops accepting new requests. Requests that have already been
+// submitted are still processed and their completions delivered. Close waits
+// until all of them have completed, so the completion channel must be drained
+// concurrently. Closing an already closed queue has no effect.
+func (async *PCIeDMAAsync) Close() error {
+	async.mutex.Lock()
+	if async.closed {
+		async.mutex.Unlock()
+		return nil
+	}
+	async.closed = true
+	close(async.requests)
+	async.mutex.Unlock()
+
+	// wait for outstanding requests to complete
+	async.wg.Wait()
+
+	// close devices if they were opened by the queue
+	var err error
+	if async.ownsDevs {
+		for _, dev := range async.devs {
+			if errClose := dev.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}
+	}
+	return err
+}
+
+// worker processes requests on a single device until the queue is closed.
+func (async *PCIeDMAAsync) worker(dev *PCIeDMA) {
+	defer async.wg.Done()
+	for req := range async.requests {
+		var n int
+		var err error
+		if req.Op == PCIE_ACCESS_WRITE {
+			n, err = dev.WriteAt(req.Data, int64(req.Addr))
+		} else {
+			n, err = dev.ReadAt(req.Data, int64(req.Addr))
+		}
+		async.completions <- PCIeDMACompletion{
+			Request:     req,
+			Transferred: n,
+			Err:         err,
+		}
+	}
+}
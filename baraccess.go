@@ -0,0 +1,280 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// BAR accesses of widths other than 32 bits and block accesses. Registers are
+// accessed with a single load or store of the access width, so addresses
+// should be aligned to it. Block accesses copy a byte slice from/to the BAR
+// with 64 bit accesses (and 32 bit accesses where the block is not 64 bit
+// aligned), e.g. to write a descriptor or a packet through a write-combining
+// mapping (see PCIeBAROpenAddrWC).
+//
+
+package gopcie
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// PCIeBAROpenAddrWC opens the PCIExpress base address register of the device
+// with the specified PCI address with a write-combining mapping. Writes to the
+// BAR may be combined and reordered by the CPU, which increases the throughput
+// of block writes. Only prefetchable BARs can be mapped write-combining; for
+// other BARs an error wrapping ErrUnsupported is returned.
+func PCIeBAROpenAddrWC(devAddr string, barId uint) (*PCIeBAR, error) {
+	barFilename := filepath.Join(pcieSysfsDevicesDir, devAddr,
+		fmt.Sprintf("resource%d_wc", barId))
+	bar, err := pcieBAROpenFile(barFilename)
+	if errors.Is(err, ErrBARNotFound) {
+		// the kernel only provides the write-combining resource file of
+		// prefetchable BARs
+		_, errStat := os.Stat(filepath.Join(pcieSysfsDevicesDir, devAddr,
+			fmt.Sprintf("resource%d", barId)))
+		if errStat == nil {
+			return nil, newPCIeError("stat BAR resource file", barFilename,
+				ErrUnsupported)
+		}
+	}
+	return bar, err
+}
+
+// Write8 writes an 8 bit value to a PCIExpress base address register. Write8
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryWrite8 for a variant returning the error).
+func (bar *PCIeBAR) Write8(addr uint32, data uint8) {
+	if err := bar.TryWrite8(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryWrite8 writes an 8 bit value to a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryWrite8(addr uint32, data uint8) error {
+	if err := bar.acquire("write BAR", addr, 1); err != nil {
+		return err
+	}
+	*(*uint8)(bar.ptr(addr)) = data
+	bar.release()
+	return nil
+}
+
+// Write16 writes a 16 bit value to a PCIExpress base address register. Write16
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryWrite16 for a variant returning the error).
+func (bar *PCIeBAR) Write16(addr uint32, data uint16) {
+	if err := bar.TryWrite16(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryWrite16 writes a 16 bit value to a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryWrite16(addr uint32, data uint16) error {
+	if err := bar.acquire("write BAR", addr, 2); err != nil {
+		return err
+	}
+	*(*uint16)(bar.ptr(addr)) = data
+	bar.release()
+	return nil
+}
+
+// Write64 writes a 64 bit value to a PCIExpress base address register. Write64
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryWrite64 for a variant returning the error).
+func (bar *PCIeBAR) Write64(addr uint32, data uint64) {
+	if err := bar.TryWrite64(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryWrite64 writes a 64 bit value to a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryWrite64(addr uint32, data uint64) error {
+	if err := bar.acquire("write BAR", addr, 8); err != nil {
+		return err
+	}
+	*(*uint64)(bar.ptr(addr)) = data
+	bar.release()
+	return nil
+}
+
+// Read8 reads an 8 bit value from a PCIExpress base address register. Read8
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryRead8 for a variant returning the error).
+func (bar *PCIeBAR) Read8(addr uint32) uint8 {
+	data, err := bar.TryRead8(addr)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TryRead8 reads an 8 bit value from a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryRead8(addr uint32) (uint8, error) {
+	if err := bar.acquire("read BAR", addr, 1); err != nil {
+		return 0, err
+	}
+	data := *(*uint8)(bar.ptr(addr))
+	bar.release()
+	return data, nil
+}
+
+// Read16 reads a 16 bit value from a PCIExpress base address register. Read16
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryRead16 for a variant returning the error).
+func (bar *PCIeBAR) Read16(addr uint32) uint16 {
+	data, err := bar.TryRead16(addr)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TryRead16 reads a 16 bit value from a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryRead16(addr uint32) (uint16, error) {
+	if err := bar.acquire("read BAR", addr, 2); err != nil {
+		return 0, err
+	}
+	data := *(*uint16)(bar.ptr(addr))
+	bar.release()
+	return data, nil
+}
+
+// Read64 reads a 64 bit value from a PCIExpress base address register. Read64
+// panics with a *PCIeError if the BAR has been closed or the address is out of
+// range (see TryRead64 for a variant returning the error).
+func (bar *PCIeBAR) Read64(addr uint32) uint64 {
+	data, err := bar.TryRead64(addr)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TryRead64 reads a 64 bit value from a PCIExpress base address register. It
+// returns a *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been
+// closed or the address is out of range.
+func (bar *PCIeBAR) TryRead64(addr uint32) (uint64, error) {
+	if err := bar.acquire("read BAR", addr, 8); err != nil {
+		return 0, err
+	}
+	data := *(*uint64)(bar.ptr(addr))
+	bar.release()
+	return data, nil
+}
+
+// WriteBlock copies data to the BAR starting at addr. Address and length of
+// the data must be multiples of 4 bytes. The data is written with 64 bit
+// accesses, and 32 bit accesses at the start and end of the block if they are
+// not 64 bit aligned. WriteBlock panics with a *PCIeError if the BAR has been
+// closed, the address range is out of range or not aligned (see TryWriteBlock
+// for a variant returning the error).
+func (bar *PCIeBAR) WriteBlock(addr uint32, data []byte) {
+	if err := bar.TryWriteBlock(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryWriteBlock copies data to the BAR like WriteBlock. It returns a
+// *PCIeError wrapping ErrClosed, ErrOutOfRange or ErrInvalidArgument if the
+// BAR has been closed, the address range is out of range or not aligned.
+func (bar *PCIeBAR) TryWriteBlock(addr uint32, data []byte) error {
+	if err := bar.acquireBlock("write BAR block", addr, len(data)); err != nil {
+		return err
+	}
+	offset := 0
+	for offset < len(data) {
+		ptr := bar.ptr(addr + uint32(offset))
+		if (addr+uint32(offset))%8 != 0 || len(data)-offset < 8 {
+			*(*uint32)(ptr) = *(*uint32)(unsafe.Pointer(&data[offset]))
+			offset += 4
+		} else {
+			*(*uint64)(ptr) = *(*uint64)(unsafe.Pointer(&data[offset]))
+			offset += 8
+		}
+	}
+	bar.release()
+	return nil
+}
+
+// ReadBlock copies data from the BAR starting at addr. Address and length of
+// the data must be multiples of 4 bytes. The data is read with 64 bit
+// accesses, and 32 bit accesses at the start and end of the block if they are
+// not 64 bit aligned. ReadBlock panics with a *PCIeError if the BAR has been
+// closed, the address range is out of range or not aligned (see TryReadBlock
+// for a variant returning the error).
+func (bar *PCIeBAR) ReadBlock(addr uint32, data []byte) {
+	if err := bar.TryReadBlock(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryReadBlock copies data from the BAR like ReadBlock. It returns a
+// *PCIeError wrapping ErrClosed, ErrOutOfRange or ErrInvalidArgument if the
+// BAR has been closed, the address range is out of range or not aligned.
+func (bar *PCIeBAR) TryReadBlock(addr uint32, data []byte) error {
+	if err := bar.acquireBlock("read BAR block", addr, len(data)); err != nil {
+		return err
+	}
+	offset := 0
+	for offset < len(data) {
+		ptr := bar.ptr(addr + uint32(offset))
+		if (addr+uint32(offset))%8 != 0 || len(data)-offset < 8 {
+			*(*uint32)(unsafe.Pointer(&data[offset])) = *(*uint32)(ptr)
+			offset += 4
+		} else {
+			*(*uint64)(unsafe.Pointer(&data[offset])) = *(*uint64)(ptr)
+			offset += 8
+		}
+	}
+	bar.release()
+	return nil
+}
+
+// acquireBlock registers a block access like acquire, but also returns an
+// error if address or size are not multiples of 4 bytes.
+func (bar *PCIeBAR) acquireBlock(op string, addr uint32, size int) error {
+	if addr%4 != 0 || size%4 != 0 {
+		return newPCIeError(fmt.Sprintf("%s at 0x%08x", op, addr),
+			bar.devName, ErrInvalidArgument)
+	}
+	return bar.acquire(op, addr, size)
+}
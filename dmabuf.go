@@ -0,0 +1,308 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Host memory buffers for designs in which the card's DMA engine masters
+// transfers to/from host memory. Buffers are backed by hugepages (which are
+// physically contiguous), locked in memory and their physical addresses are
+// resolved via /proc/self/pagemap, so that they can be programmed into the
+// card's DMA descriptor registers.
+//
+
+package gopcie
+
+import (
+	"bufio"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	DMA_BUFFER_HUGEPAGE = 1
+	DMA_BUFFER_LOCK     = 2
+)
+
+// pagemap entry bits (see Documentation/admin-guide/mm/pagemap.rst).
+const (
+	pagemapPresent = 1 << 63
+	pagemapPfnMask = (1 << 55) - 1
+)
+
+// PCIeDMABuffer is a host memory buffer that can be accessed by the card's DMA
+// engine.
+type PCIeDMABuffer struct {
+	data     []byte
+	pageSize uint64
+	locked   bool
+}
+
+// DMABufferAlloc allocates a host memory buffer of the specified size. The
+// flags determine whether the buffer is backed by hugepages
+// (DMA_BUFFER_HUGEPAGE) and whether it is locked in memory (DMA_BUFFER_LOCK).
+// Hugepage-backed buffers require hugepages to be reserved (see
+// /proc/sys/vm/nr_hugepages). The size is rounded up to a multiple of the
+// (huge)page size. Buffers must be locked if their physical addresses are
+// programmed into the card, otherwise the kernel may move or swap them.
+func DMABufferAlloc(size uint64, flags int) (*PCIeDMABuffer, error) {
+	return dmaBufferAlloc(size, flags, -1)
+}
+
+// dmaBufferAlloc allocates a host memory buffer of the specified size. If the
+// NUMA node is not negative, the memory is allocated on that node.
+func dmaBufferAlloc(size uint64, flags int, numaNode int) (*PCIeDMABuffer,
+	error) {
+	if size == 0 {
+		return nil, newPCIeError("allocate dma buffer", "", ErrInvalidArgument)
+	}
+
+	// determine page size
+	pageSize := uint64(os.Getpagesize())
+	mmapFlags := syscall.MAP_PRIVATE | syscall.MAP_ANONYMOUS
+	if (flags & DMA_BUFFER_HUGEPAGE) != 0 {
+		var err error
+		pageSize, err = hugepageSize()
+		if err != nil {
+			return nil, err
+		}
+		mmapFlags |= mapHugetlb
+	}
+
+	// pre-fault the memory, unless a memory policy must be set before the
+	// pages are faulted in
+	if numaNode < 0 {
+		mmapFlags |= syscall.MAP_POPULATE
+	}
+
+	// round size up to a multiple of the page size
+	if size > uint64(math.MaxInt)-pageSize {
+		return nil, newPCIeError("allocate dma buffer", "", ErrInvalidArgument)
+	}
+	size = (size + pageSize - 1) / pageSize * pageSize
+
+	// allocate memory
+	data, err := syscall.Mmap(-1, 0, int(size),
+		syscall.PROT_READ|syscall.PROT_WRITE, mmapFlags)
+	if err != nil {
+		if (flags & DMA_BUFFER_HUGEPAGE) != 0 {
+			return nil, newPCIeError("allocate hugepages (are enough "+
+				"hugepages reserved in /proc/sys/vm/nr_hugepages?)", "", err)
+		}
+		return nil, newPCIeError("allocate dma buffer", "", err)
+	}
+
+	// bind memory to NUMA node and fault in the pages
+	if numaNode >= 0 {
+		if err := numaBind(data, numaNode); err != nil {
+			syscall.Munmap(data)
+			return nil, err
+		}
+		for offset := uint64(0); offset < size; offset += pageSize {
+			data[offset] = 0
+		}
+	}
+
+	return dmaBufferInit(data, pageSize, flags)
+}
+
+// DMABufferAllocHugetlbfs allocates a host memory buffer of the specified size
+// backed by a file on a mounted hugetlbfs (e.g. /dev/hugepages). This allows
+// to use a hugepage size different from the system's default one by using the
+// respective mount point. The size is rounded up to a multiple of the hugepage
+// size. The flags have the same meaning as for DMABufferAlloc
+// (DMA_BUFFER_HUGEPAGE is implied).
+func DMABufferAllocHugetlbfs(mountPath string, size uint64,
+	flags int) (*PCIeDMABuffer, error) {
+	if size == 0 {
+		return nil, newPCIeError("allocate dma buffer", mountPath,
+			ErrInvalidArgument)
+	}
+
+	// the block size of a hugetlbfs is its hugepage size
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(mountPath, &statfs); err != nil {
+		return nil, newPCIeError("stat hugetlbfs", mountPath, err)
+	}
+	pageSize := uint64(statfs.Bsize)
+
+	// round size up to a multiple of the page size
+	if size > uint64(math.MaxInt)-pageSize {
+		return nil, newPCIeError("allocate dma buffer", mountPath,
+			ErrInvalidArgument)
+	}
+	size = (size + pageSize - 1) / pageSize * pageSize
+
+	// create file on hugetlbfs. it is removed right away, the memory is
+	// released when it is unmapped
+	file, err := ioutil.TempFile(mountPath, "gopcie")
+	if err != nil {
+		return nil, newPCIeError("create file on hugetlbfs", mountPath, err)
+	}
+	os.Remove(file.Name())
+	defer file.Close()
+
+	// memory-map the file
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return nil, newPCIeError("allocate hugepages (are enough hugepages "+
+			"reserved for the hugetlbfs?)", mountPath, err)
+	}
+
+	return dmaBufferInit(data, pageSize, flags|DMA_BUFFER_HUGEPAGE)
+}
+
+// dmaBufferInit creates a buffer from allocated memory and locks it if
+// requested.
+func dmaBufferInit(data []byte, pageSize uint64,
+	flags int) (*PCIeDMABuffer, error) {
+	buf := &PCIeDMABuffer{
+		data:     data,
+		pageSize: pageSize,
+	}
+
+	// lock memory
+	if (flags & DMA_BUFFER_LOCK) != 0 {
+		if err := syscall.Mlock(data); err != nil {
+			syscall.Munmap(data)
+			return nil, newPCIeError("lock dma buffer (missing CAP_IPC_LOCK "+
+				"or RLIMIT_MEMLOCK too low?)", "", err)
+		}
+		buf.locked = true
+	}
+
+	return buf, nil
+}
+
+// Close releases the buffer. Closing an already closed buffer has no effect.
+func (buf *PCIeDMABuffer) Close() error {
+	if buf.data == nil {
+		return nil
+	}
+	if buf.locked {
+		syscall.Munlock(buf.data)
+	}
+	if err := syscall.Munmap(buf.data); err != nil {
+		return newPCIeError("free dma buffer", "", err)
+	}
+	buf.data = nil
+	return nil
+}
+
+// Bytes returns the memory of the buffer.
+func (buf *PCIeDMABuffer) Bytes() []byte {
+	return buf.data
+}
+
+// Size returns the size of the buffer.
+func (buf *PCIeDMABuffer) Size() uint64 {
+	return uint64(len(buf.data))
+}
+
+// PageSize returns the size of the pages backing the buffer. Each page is
+// physically contiguous, so the card can transfer up to PageSize bytes
+// starting at a page-aligned offset using a single physical address.
+func (buf *PCIeDMABuffer) PageSize() uint64 {
+	return buf.pageSize
+}
+
+// PhysAddr returns the physical address of the byte at the specified offset
+// of the buffer. Resolving physical addresses requires the CAP_SYS_ADMIN
+// capability.
+func (buf *PCIeDMABuffer) PhysAddr(offset uint64) (uint64, error) {
+	if offset >= uint64(len(buf.data)) {
+		return 0, newPCIeError("resolve physical address", "", ErrOutOfRange)
+	}
+
+	// pagemap contains one 64 bit entry per (regular sized) virtual page
+	vaddr := uint64(uintptr(unsafe.Pointer(&buf.data[0]))) + offset
+	sysPageSize := uint64(os.Getpagesize())
+
+	pagemap, err := os.Open("/proc/self/pagemap")
+	if err != nil {
+		return 0, newPCIeError("open", "/proc/self/pagemap", err)
+	}
+	defer pagemap.Close()
+
+	var entryBuf [8]byte
+	n, err := pagemap.ReadAt(entryBuf[:], int64(vaddr/sysPageSize*8))
+	if err == nil && n != len(entryBuf) {
+		err = ErrShortTransfer
+	}
+	if err != nil {
+		return 0, newPCIeError("read", "/proc/self/pagemap", err)
+	}
+	entry := *(*uint64)(unsafe.Pointer(&entryBuf[0]))
+
+	if (entry & pagemapPresent) == 0 {
+		return 0, newPCIeError("resolve physical address (page not present)",
+			"/proc/self/pagemap", syscall.EFAULT)
+	}
+
+	// without CAP_SYS_ADMIN the kernel reports a page frame number of zero
+	pfn := entry & pagemapPfnMask
+	if pfn == 0 {
+		return 0, newPCIeError("resolve physical address (missing "+
+			"CAP_SYS_ADMIN?)", "/proc/self/pagemap", syscall.EPERM)
+	}
+
+	return pfn*sysPageSize + vaddr%sysPageSize, nil
+}
+
+// hugepageSize returns the system's default hugepage size.
+func hugepageSize() (uint64, error) {
+	meminfo, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, newPCIeError("open", "/proc/meminfo", err)
+	}
+	defer meminfo.Close()
+
+	scanner := bufio.NewScanner(meminfo)
+	for scanner.Scan() {
+		// line format is "Hugepagesize:       2048 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "Hugepagesize:" {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil || fields[2] != "kB" {
+			break
+		}
+		return size * 1024, nil
+	}
+
+	return 0, newPCIeError("determine hugepage size", "/proc/meminfo",
+		ErrUnsupported)
+}
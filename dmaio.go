@@ -0,0 +1,180 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Standard library io interfaces for PCIeDMA. PCIeDMA implements io.ReaderAt,
+// io.WriterAt and io.Seeker, with offsets being card memory addresses. This
+// allows to use the device with stock library code, e.g.:
+//
+//   io.Copy(io.NewOffsetWriter(dev, addr), file)     // file to card memory
+//   io.Copy(file, io.NewSectionReader(dev, addr, n)) // card memory to file
+//
+// ReadWriter returns an io.ReadWriteSeeker that reads and writes at the
+// device's current offset, which is set via Seek.
+//
+
+package gopcie
+
+import (
+	"context"
+	"io"
+)
+
+// ReadAt reads len(p) bytes from card memory starting at address off. It
+// implements io.ReaderAt. If the card memory size is set (see SetSize), reads
+// are truncated at the end of card memory and io.EOF is returned.
+func (dev *PCIeDMA) ReadAt(p []byte, off int64) (int, error) {
+	// check if access mode allows reading
+	if (dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return 0, ErrNotReadable
+	}
+	if off < 0 {
+		return 0, newPCIeTransferError("read", dev.devName, uint64(off),
+			len(p), 0, ErrOutOfRange)
+	}
+
+	// truncate read at the end of card memory
+	size := dev.Size()
+	eof := false
+	if size > 0 {
+		if off >= size {
+			return 0, io.EOF
+		}
+		if int64(len(p)) > size-off {
+			p = p[:size-off]
+			eof = true
+		}
+	}
+
+	n, err := dev.transferContext(context.Background(), "read", uint64(off), p)
+	if err == nil && eof {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt writes len(p) bytes to card memory starting at address off. It
+// implements io.WriterAt. If the card memory size is set (see SetSize), writes
+// beyond the end of card memory fail.
+func (dev *PCIeDMA) WriteAt(p []byte, off int64) (int, error) {
+	// check if access mode allows writing
+	if (dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
+		return 0, ErrNotWritable
+	}
+	size := dev.Size()
+	if off < 0 || (size > 0 && off+int64(len(p)) > size) {
+		return 0, newPCIeTransferError("write", dev.devName, uint64(off),
+			len(p), 0, ErrOutOfRange)
+	}
+
+	return dev.transferContext(context.Background(), "write", uint64(off), p)
+}
+
+// Seek sets the device's current offset (i.e. the card memory address used by
+// the io.ReadWriteSeeker returned by ReadWriter). It implements io.Seeker.
+// Seeking relative to the end (io.SeekEnd) requires the card memory size to be
+// set (see SetSize).
+func (dev *PCIeDMA) Seek(offset int64, whence int) (int64, error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += dev.offset
+	case io.SeekEnd:
+		if dev.size == 0 {
+			return dev.offset, newPCIeError("seek", dev.devName, ErrUnsupported)
+		}
+		offset += dev.size
+	default:
+		return dev.offset, newPCIeError("seek", dev.devName,
+			ErrInvalidArgument)
+	}
+
+	if offset < 0 {
+		return dev.offset, newPCIeError("seek", dev.devName, ErrOutOfRange)
+	}
+	dev.offset = offset
+	return offset, nil
+}
+
+// SetSize sets the size of the card memory accessible through the device. It
+// is used to detect the end of card memory. A size of zero (the default) means
+// the size is unknown.
+func (dev *PCIeDMA) SetSize(size int64) error {
+	if size < 0 {
+		return newPCIeError("set size", dev.devName, ErrInvalidArgument)
+	}
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	dev.size = size
+	return nil
+}
+
+// Size returns the size of the card memory accessible through the device. It
+// returns zero if the size is unknown.
+func (dev *PCIeDMA) Size() int64 {
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+	return dev.size
+}
+
+// ReadWriter returns an io.ReadWriteSeeker that reads and writes card memory at
+// the device's current offset and advances it by the number of bytes
+// transferred. Reads only return io.EOF if the card memory size is set (see
+// SetSize). The returned value is not safe for concurrent use.
+func (dev *PCIeDMA) ReadWriter() io.ReadWriteSeeker {
+	return pcieDMAReadWriter{dev}
+}
+
+// pcieDMAReadWriter implements io.ReadWriteSeeker using the device's current
+// offset.
+type pcieDMAReadWriter struct {
+	dev *PCIeDMA
+}
+
+func (rw pcieDMAReadWriter) Read(p []byte) (int, error) {
+	offset, _ := rw.dev.Seek(0, io.SeekCurrent)
+	n, err := rw.dev.ReadAt(p, offset)
+	rw.dev.Seek(int64(n), io.SeekCurrent)
+	return n, err
+}
+
+func (rw pcieDMAReadWriter) Write(p []byte) (int, error) {
+	offset, _ := rw.dev.Seek(0, io.SeekCurrent)
+	n, err := rw.dev.WriteAt(p, offset)
+	rw.dev.Seek(int64(n), io.SeekCurrent)
+	return n, err
+}
+
+func (rw pcieDMAReadWriter) Seek(offset int64, whence int) (int64, error) {
+	return rw.dev.Seek(offset, whence)
+}
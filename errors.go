@@ -0,0 +1,163 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Error values and types. Errors returned by the package either are one of the
+// sentinel errors below or wrap them or the underlying system call error, so
+// that callers can inspect them using errors.Is and errors.As, e.g.:
+//
+//   errors.Is(err, syscall.EACCES)
+//   errors.Is(err, gopcie.ErrShortTransfer)
+//
+
+package gopcie
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	// ErrInvalidAccessMode is returned when opening a device with an invalid
+	// access mode.
+	ErrInvalidAccessMode = errors.New("invalid access mode")
+	// ErrNotReadable is returned when reading from a device that has not been
+	// opened for reading.
+	ErrNotReadable = errors.New("access mode does not allow reading")
+	// ErrNotWritable is returned when writing to a device that has not been
+	// opened for writing.
+	ErrNotWritable = errors.New("access mode does not allow writing")
+	// ErrShortTransfer is returned when a transfer completed with fewer bytes
+	// than requested.
+	ErrShortTransfer = errors.New("short transfer")
+	// ErrDeviceNotFound is returned when a device could not be found.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrBARNotFound is returned when a BAR could not be found.
+	ErrBARNotFound = errors.New("BAR not found")
+	// ErrOutOfRange is returned when accessing an address outside of a buffer
+	// or memory region.
+	ErrOutOfRange = errors.New("address out of range")
+	// ErrInvalidArgument is returned when a function is called with an invalid
+	// argument.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrUnsupported is returned when the system or device does not support
+	// the requested operation.
+	ErrUnsupported = errors.New("operation not supported")
+	// ErrTimeout is returned when waiting for an interrupt timed out.
+	ErrTimeout = errors.New("timeout")
+	// ErrClosed is returned when using a device that has been closed.
+	ErrClosed = errors.New("device closed")
+	// ErrBusy is returned when starting an operation that has already been
+	// started and may only run once at a time.
+	ErrBusy = errors.New("operation already in progress")
+	// ErrMismatch is returned when card memory does not match the expected
+	// data.
+	ErrMismatch = errors.New("data mismatch")
+)
+
+// PCIeError records a failed operation on a device or system file.
+type PCIeError struct {
+	Op   string // failed operation, e.g. "open device"
+	Path string // device or file path (may be empty)
+	Err  error  // underlying error
+}
+
+// newPCIeError creates a PCIeError. If the underlying error is an os package
+// error wrapping a system call error, the system call error is recorded
+// directly, since path and operation are already recorded by the PCIeError.
+func newPCIeError(op, path string, err error) *PCIeError {
+	return &PCIeError{Op: op, Path: path, Err: unwrapOsError(err)}
+}
+
+func (e *PCIeError) Error() string {
+	if e.Path == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *PCIeError) Unwrap() error {
+	return e.Err
+}
+
+// PCIeTransferError records a failed DMA transfer.
+type PCIeTransferError struct {
+	Op          string // "read" or "write"
+	Path        string // device path
+	Addr        uint64 // card address of the transfer
+	Requested   int    // number of bytes requested to be transferred
+	Transferred int    // number of bytes actually transferred
+	Err         error  // underlying error
+}
+
+// newPCIeTransferError creates a PCIeTransferError. If no underlying error is
+// specified or the end of the device was reached, the transfer is considered to
+// be short.
+func newPCIeTransferError(op, path string, addr uint64, requested,
+	transferred int, err error) *PCIeTransferError {
+	if err == nil || err == io.EOF {
+		err = ErrShortTransfer
+	}
+	return &PCIeTransferError{
+		Op:          op,
+		Path:        path,
+		Addr:        addr,
+		Requested:   requested,
+		Transferred: transferred,
+		Err:         unwrapOsError(err),
+	}
+}
+
+func (e *PCIeTransferError) Error() string {
+	return fmt.Sprintf("%s %s at 0x%x: %d of %d bytes transferred: %s", e.Op,
+		e.Path, e.Addr, e.Transferred, e.Requested, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *PCIeTransferError) Unwrap() error {
+	return e.Err
+}
+
+// unwrapOsError returns the system call error wrapped by an os package error.
+// Other errors are returned unchanged.
+func unwrapOsError(err error) error {
+	switch osErr := err.(type) {
+	case *os.PathError:
+		return osErr.Err
+	case *os.SyscallError:
+		return osErr.Err
+	case *os.LinkError:
+		return osErr.Err
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// User interrupt event files of the DMA kernel driver. Drivers such as Xilinx
+// XDMA create one event character device per user interrupt vector (e.g.
+// /dev/xdma0_events_0). Reading the device blocks until the interrupt fires.
+//
+
+package gopcie
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// pcieEventPollInterval is the interval in which blocked event waits check
+// whether their context is done.
+const pcieEventPollInterval = 100 * time.Millisecond
+
+// PCIeEvent implements waiting for user interrupts signaled through an event
+// character device of the DMA kernel driver.
+type PCIeEvent struct {
+	fd      int
+	devName string
+
+	// the close mutex protects the file descriptor against being closed while
+	// it is used. waiting for events holds it for at most
+	// pcieEventPollInterval at a time, so that Close does not block
+	closeMutex sync.RWMutex
+	closed     bool
+
+	// notification goroutines
+	mutex      sync.Mutex
+	notifyStop chan struct{}
+	notifyWg   sync.WaitGroup
+}
+
+// PCIeEventOpen opens a user interrupt event device. The function expects the
+// device name (i.e. /dev/...).
+func PCIeEventOpen(devName string) (*PCIeEvent, error) {
+	fd, err := syscall.Open(devName, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, newPCIeError("open device", devName, err)
+	}
+	return &PCIeEvent{
+		fd:         fd,
+		devName:    devName,
+		notifyStop: make(chan struct{}),
+	}, nil
+}
+
+// Close closes the event device. All notification channels are closed. Waits
+// in progress return an error wrapping ErrClosed. Closing an already closed
+// device has no effect.
+func (ev *PCIeEvent) Close() error {
+	// once the device is marked closed, the file descriptor is not used
+	// anymore
+	ev.closeMutex.Lock()
+	if ev.closed {
+		ev.closeMutex.Unlock()
+		return nil
+	}
+	ev.closed = true
+	ev.closeMutex.Unlock()
+
+	// stop notification goroutines
+	ev.mutex.Lock()
+	notifyStop := ev.notifyStop
+	ev.notifyStop = nil
+	ev.mutex.Unlock()
+	close(notifyStop)
+	ev.notifyWg.Wait()
+
+	if err := syscall.Close(ev.fd); err != nil {
+		return newPCIeError("close device", ev.devName, err)
+	}
+	return nil
+}
+
+// Wait blocks until the user interrupt fires or the context is done. It
+// returns the event value reported by the driver (for XDMA, the number of
+// interrupts since the last read). If the context is done, the context's error
+// is returned. If the device is closed while waiting, an error wrapping
+// ErrClosed is returned.
+func (ev *PCIeEvent) Wait(ctx context.Context) (uint32, error) {
+	for {
+		// wait in slices of the poll interval, so that the context can be
+		// checked and the device can be closed in between. do not wait past
+		// the context's deadline
+		timeout := pcieEventPollInterval
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+
+		value, ready, err := ev.waitPoll(timeout)
+		if err != nil {
+			return 0, err
+		}
+		if ready {
+			return value, nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// waitPoll waits for an event for at most the specified timeout. If an event is
+// received, it returns true and the event value.
+func (ev *PCIeEvent) waitPoll(timeout time.Duration) (uint32, bool, error) {
+	ev.closeMutex.RLock()
+	defer ev.closeMutex.RUnlock()
+	if ev.closed {
+		return 0, false, newPCIeError("wait for event", ev.devName, ErrClosed)
+	}
+
+	// wait for interrupt
+	ready, err := pollReadable(ev.fd, timeout)
+	if err != nil {
+		return 0, false, newPCIeError("wait for event", ev.devName, err)
+	}
+	if !ready {
+		return 0, false, nil
+	}
+
+	// read event value
+	var buf [4]byte
+	n, err := syscall.Read(ev.fd, buf[:])
+	if err == nil && n != len(buf) {
+		err = ErrShortTransfer
+	}
+	if err != nil {
+		return 0, false, newPCIeError("read event", ev.devName, err)
+	}
+	return *(*uint32)(unsafe.Pointer(&buf[0])), true, nil
+}
+
+// Notify delivers events on the returned channel until the context is done or
+// the event device is closed. The channel is closed afterwards. A blocked send
+// on the channel also ends when the context is done or the device is closed.
+func (ev *PCIeEvent) Notify(ctx context.Context, bufSize int) (<-chan uint32,
+	error) {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	if ev.notifyStop == nil {
+		return nil, newPCIeError("start event notification", ev.devName,
+			ErrClosed)
+	}
+	notifyStop := ev.notifyStop
+
+	// derive a context that is canceled when the device is closed
+	ctx, cancel := context.WithCancel(ctx)
+
+	ch := make(chan uint32, bufSize)
+	ev.notifyWg.Add(2)
+	go func() {
+		defer ev.notifyWg.Done()
+		select {
+		case <-notifyStop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer ev.notifyWg.Done()
+		defer close(ch)
+		defer cancel()
+		for {
+			value, err := ev.Wait(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
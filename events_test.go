@@ -0,0 +1,125 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tests of user interrupt event devices. A named pipe takes the place of the
+// driver's event device.
+//
+
+package gopcie
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// tempEventFifo creates a named pipe that is opened in place of an event
+// device. It returns the pipe's name and a writer file descriptor, which keeps
+// the pipe open so that waits on it block.
+func tempEventFifo(t *testing.T) (string, int) {
+	name := filepath.Join(t.TempDir(), "events")
+	if err := syscall.Mkfifo(name, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	return name, fd
+}
+
+func TestPCIeEventWait(t *testing.T) {
+	name, fd := tempEventFifo(t)
+	ev, err := PCIeEventOpen(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ev.Close()
+
+	// the context's deadline ends the wait
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	if _, err := ev.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait returned %v, expected %v", err,
+			context.DeadlineExceeded)
+	}
+
+	// the event value is read
+	if _, err := syscall.Write(fd, []byte{3, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	value, err := ev.Wait(context.Background())
+	if err != nil || value != 3 {
+		t.Errorf("wait returned %d, %v, expected 3", value, err)
+	}
+}
+
+func TestPCIeEventConcurrentClose(t *testing.T) {
+	name, _ := tempEventFifo(t)
+	ev, err := PCIeEventOpen(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// waits racing with Close fail with ErrClosed, but never use the closed
+	// file descriptor
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := ev.Wait(context.Background())
+			done <- err
+		}()
+	}
+	notify, err := ev.Notify(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := ev.Close(); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; !errors.Is(err, ErrClosed) {
+			t.Errorf("wait returned %v, expected %v", err, ErrClosed)
+		}
+	}
+	if _, ok := <-notify; ok {
+		t.Error("notification channel not closed")
+	}
+	if _, err := ev.Wait(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("wait after close returned %v, expected %v", err, ErrClosed)
+	}
+}
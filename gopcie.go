@@ -25,7 +25,7 @@
 //   - Andreas Oeldemann <andreas.oeldemann@tum.de>
 //
 // Date Created:        June 9th 2017
-// Date Last Modified:  July 4th 2017
+// Date Last Modified:  October 19th 2026
 //
 // Description:
 //
@@ -44,14 +44,15 @@
 package gopcie
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -62,8 +63,31 @@ const (
 
 // PCIeDMA implements PCIExpress DMA reads and writes.
 type PCIeDMA struct {
+	// the mutex protects the device file against being closed while transfers
+	// are in progress
+	mutex      sync.RWMutex
 	fd         *os.File
+	devName    string
 	accessMode int
+
+	// current offset and card memory size (see Seek and SetSize)
+	offset int64
+	size   int64
+
+	// transfer chunking (see SetMaxTransferSize and SetTransferAlignment)
+	maxTransferSize int
+	transferAlign   uint64
+
+	// hung transfer watchdog (see SetWatchdog)
+	watchdogTimeout time.Duration
+	watchdogHook    PCIeDMAWatchdogFunc
+
+	// host memory buffer pool (see BufferPool)
+	bufferPool      *PCIeDMABufferPool
+	bufferPoolOwned bool
+
+	// transfer metrics (see EnableMetrics)
+	metrics *pcieDMAMetrics
 }
 
 // PCIeDMAOpen opens a PCIExpress DMA device. The function expects the
@@ -71,187 +95,205 @@ type PCIeDMA struct {
 func PCIeDMAOpen(devName string, accessMode int) (*PCIeDMA, error) {
 	// check if access mode is set
 	if (accessMode & (PCIE_ACCESS_READ | PCIE_ACCESS_WRITE)) == 0 {
-		return nil, errors.New("invalid access mode")
+		return nil, ErrInvalidAccessMode
 	}
 
 	// open device
 	fd, err := os.OpenFile(devName, os.O_RDWR, 0600)
 	if err != nil {
-		return nil,
-			errors.New("could not open device")
+		return nil, newPCIeError("open device", devName, err)
 	}
 
 	// create device
 	dev := PCIeDMA{
 		fd:         fd,
+		devName:    devName,
 		accessMode: accessMode,
 	}
 
 	return &dev, nil
 }
 
-// Close closes the PCIExpress DMA device.
-func (dev *PCIeDMA) Close() {
-	dev.fd.Close()
+// Close closes the PCIExpress DMA device. It waits for transfers in progress to
+// complete. Closing an already closed device has no effect.
+func (dev *PCIeDMA) Close() error {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if dev.fd == nil {
+		return nil
+	}
+
+	// stop publishing metrics
+	dev.disableMetrics()
+
+	// free buffer pool created by the device
+	if dev.bufferPoolOwned {
+		dev.bufferPool.Close()
+		dev.bufferPool = nil
+		dev.bufferPoolOwned = false
+	}
+
+	err := dev.fd.Close()
+	dev.fd = nil
+	if err != nil {
+		return newPCIeError("close device", dev.devName, err)
+	}
+	return nil
 }
 
-// Write performs a DMA write transfer.
+// Write performs a DMA write transfer. Transfers larger than the maximum
+// transfer size are split into chunks (see SetMaxTransferSize). If the
+// transfer fails, the returned *PCIeTransferError reports how many bytes were
+// written.
 func (dev *PCIeDMA) Write(addr uint64, data []byte) error {
 	// check if access mode allows writing
 	if (dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
-		return errors.New("access mode does not allow writing")
+		return ErrNotWritable
 	}
 
 	// perform write transfer
-	nBytesWritten, err := dev.fd.WriteAt(data, int64(addr))
-	if err != nil || nBytesWritten != len(data) {
-		return errors.New("could not write to device")
-	}
-	return nil
+	_, err := dev.transferContext(context.Background(), "write", addr, data)
+	return err
 }
 
-// Read performs a DMA read transfer.
+// Read performs a DMA read transfer. Transfers larger than the maximum transfer
+// size are split into chunks (see SetMaxTransferSize). If the transfer fails,
+// the returned *PCIeTransferError reports how many bytes were read.
 func (dev *PCIeDMA) Read(addr uint64, data []byte) error {
 	// check if access mode allows reading
 	if (dev.accessMode & PCIE_ACCESS_READ) == 0 {
-		return errors.New("access mode does not allow reading")
+		return ErrNotReadable
 	}
 
 	// perform read transfer
-	nBytesRead, err := dev.fd.ReadAt(data, int64(addr))
-	if err != nil || nBytesRead != len(data) {
-		return errors.New("could not read from device")
-	}
-	return nil
+	_, err := dev.transferContext(context.Background(), "read", addr, data)
+	return err
 }
 
 // PCIeBAR implements reads and writes from/to a PCIExpress base address
 // registers.
 type PCIeBAR struct {
-	fd  *os.File
-	bar []byte
+	fd      *os.File
+	devName string
+	bar     []byte
+	size    int
+	munmap  func([]byte) error
+
+	// accesses do not lock, so that they do not slow down register accesses.
+	// instead, accesses in progress are counted and Close waits for them to
+	// complete after marking the BAR closed, before the BAR is unmapped
+	closed atomic.Bool
+	active atomic.Int64
+}
+
+// newPCIeBAR creates a PCIeBAR of a memory-mapped BAR. The BAR is unmapped with
+// syscall.Munmap when it is closed.
+func newPCIeBAR(fd *os.File, devName string, bar []byte) *PCIeBAR {
+	return &PCIeBAR{fd: fd, devName: devName, bar: bar, size: len(bar),
+		munmap: syscall.Munmap}
 }
 
 // PCIeBAROpen opens the PCIExpress base address register. The function expects
 // the function, vendor, device and bar ID of the bar to be opened.
 func PCIeBAROpen(functionId, vendorId, deviceId, barId uint) (*PCIeBAR, error) {
-	barFilename := ""
-
-	// list system devices directory
-	devDirs, err := ioutil.ReadDir("/sys/bus/pci/devices")
+	// find the device in sysfs
+	devAddr, err := pcieDeviceFind(functionId, vendorId, deviceId)
 	if err != nil {
-		return nil, errors.New("could not read /sys/bus/pci/devices directory")
-	}
-
-	// iterate over all devices
-	for _, devDir := range devDirs {
-
-		// not the device we are looking for if directory name does not start
-		// with "0000:"
-		if devDir.Name()[0:5] != "0000:" {
-			continue
-		}
-
-		// get function id and see if it matches the one we are looking for
-		functionIdFound, err := strconv.ParseUint(
-			devDir.Name()[len(devDir.Name())-1:], 10, 32)
-		if err != nil || uint(functionIdFound) != functionId {
-			continue
-		}
-
-		// read device vendor file
-		vendorFilename := filepath.Join("/sys/bus/pci/devices", devDir.Name(),
-			"vendor")
-		vendorFile, err := ioutil.ReadFile(vendorFilename)
-		if err != nil {
-			return nil, errors.New("could not open pci vendor file")
-		}
-		vendorFileStr := string(vendorFile)
-
-		// device vendor file should have only one line and start with "0x"
-		if vendorFileStr[0:2] != "0x" ||
-			strings.Index(vendorFileStr, "\n") != len(vendorFileStr)-1 {
-			continue
-		}
-		vendorFileStr = vendorFileStr[0 : len(vendorFileStr)-1]
-
-		// get vendor id
-		vendorIdFound, err := strconv.ParseInt(vendorFileStr[2:], 16, 32)
-		if err != nil || uint(vendorIdFound) != vendorId {
-			continue
-		}
-
-		// read pci device file
-		deviceFilename := filepath.Join("/sys/bus/pci/devices", devDir.Name(),
-			"device")
-		deviceFile, err := ioutil.ReadFile(deviceFilename)
-		if err != nil {
-			return nil, errors.New("could not open pci device file")
-		}
-		deviceFileStr := string(deviceFile)
-
-		// device file should have only one line and start with "0x"
-		if deviceFileStr[0:2] != "0x" ||
-			strings.Index(deviceFileStr, "\n") != len(deviceFileStr)-1 {
-			continue
-		}
-		deviceFileStr = deviceFileStr[0 : len(deviceFileStr)-1]
-
-		// get device id
-		deviceIdFound, err := strconv.ParseInt(deviceFileStr[2:], 16, 32)
-		if err != nil || uint(deviceIdFound) != deviceId {
-			continue
-		}
-
-		// all ids matched. found it!
-		barFilename = filepath.Join("/sys/bus/pci/devices", devDir.Name(),
-			fmt.Sprintf("resource%d", barId))
-		break
+		return nil, err
 	}
+	return PCIeBAROpenAddr(devAddr, barId)
+}
 
-	// check if the BAR resource file was found
-	if len(barFilename) == 0 {
-		return nil, errors.New("could not find BAR")
-	}
+// PCIeBAROpenAddr opens the PCIExpress base address register of the device with
+// the specified PCI address (e.g. "0000:01:00.0").
+func PCIeBAROpenAddr(devAddr string, barId uint) (*PCIeBAR, error) {
+	return pcieBAROpenFile(filepath.Join(pcieSysfsDevicesDir, devAddr,
+		fmt.Sprintf("resource%d", barId)))
+}
 
+// pcieBAROpenFile memory-maps a sysfs BAR resource file.
+func pcieBAROpenFile(barFilename string) (*PCIeBAR, error) {
 	// stat the BAR resource file to get its size
 	barFileInfo, err := os.Stat(barFilename)
+	if os.IsNotExist(err) {
+		return nil, newPCIeError("stat BAR resource file", barFilename,
+			ErrBARNotFound)
+	}
 	if err != nil {
-		return nil, errors.New("could not stat BAR resource file")
+		return nil, newPCIeError("stat BAR resource file", barFilename, err)
 	}
 
 	// open BAR resource file
 	fd, err := os.OpenFile(barFilename, os.O_RDWR|os.O_SYNC, 0666)
 	if err != nil {
-		return nil, errors.New("could not open BAR resource file")
+		return nil, newPCIeError("open BAR resource file", barFilename, err)
 	}
 
 	// memory-map the BAR
 	bar, err := syscall.Mmap(int(fd.Fd()), 0, int(barFileInfo.Size()),
 		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
 	if err != nil {
-		return nil, errors.New("could not memory-map BAR")
+		fd.Close()
+		return nil, newPCIeError("memory-map BAR", barFilename, err)
 	}
 
-	return &PCIeBAR{fd, bar}, nil
+	return newPCIeBAR(fd, barFilename, bar), nil
 }
 
-// Close closes the PCIExpress base address register.
+// Close closes the PCIExpress base address register. It waits for accesses in
+// progress to complete. Closing an already closed BAR has no effect.
 func (bar *PCIeBAR) Close() error {
-	// un-memory map the BAR
-	err := syscall.Munmap(bar.bar)
-	if err != nil {
-		return errors.New("could not un-memory-map BAR")
+	if !bar.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// wait for accesses in progress. accesses starting after the BAR has been
+	// marked closed fail
+	for bar.active.Load() != 0 {
+		runtime.Gosched()
+	}
+
+	// un-memory map the BAR and close BAR resource file
+	errMunmap := bar.munmap(bar.bar)
+	errClose := bar.fd.Close()
+	if errMunmap != nil {
+		return newPCIeError("un-memory-map BAR", bar.devName, errMunmap)
+	}
+	if errClose != nil {
+		return newPCIeError("close BAR resource file", bar.devName, errClose)
 	}
-	// close BAR resource file
-	bar.fd.Close()
 	return nil
 }
 
-// Write writes data to a PCIExpress base address register.
+// Size returns the size of the BAR in bytes. It returns zero if the BAR has
+// been closed.
+func (bar *PCIeBAR) Size() int {
+	if bar.closed.Load() {
+		return 0
+	}
+	return bar.size
+}
+
+// Write writes data to a PCIExpress base address register. Write panics with a
+// *PCIeError if the BAR has been closed or the address is out of range (see
+// TryWrite for a variant returning the error).
 func (bar *PCIeBAR) Write(addr, data uint32) {
-	*(*uint32)(unsafe.Pointer(uintptr(unsafe.Pointer(&bar.bar[0])) +
-		uintptr(addr))) = data
+	if err := bar.TryWrite(addr, data); err != nil {
+		panic(err)
+	}
+}
+
+// TryWrite writes data to a PCIExpress base address register. It returns a
+// *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been closed or
+// the address is out of range.
+func (bar *PCIeBAR) TryWrite(addr, data uint32) error {
+	if err := bar.acquire("write BAR", addr, 4); err != nil {
+		return err
+	}
+	*(*uint32)(bar.ptr(addr)) = data
+	bar.release()
+	return nil
 }
 
 // WriteMask writes data to a PCIExpress base address register. The specified
@@ -262,8 +304,55 @@ func (bar *PCIeBAR) WriteMask(addr, data, mask uint32) {
 	bar.Write(addr, wr_data)
 }
 
-// Read reads data from a PCIExpress base address register.
+// Read reads data from a PCIExpress base address register. Read panics with a
+// *PCIeError if the BAR has been closed or the address is out of range (see
+// TryRead for a variant returning the error).
 func (bar *PCIeBAR) Read(addr uint32) uint32 {
-	return *(*uint32)(unsafe.Pointer(uintptr(unsafe.Pointer(&bar.bar[0])) +
-		uintptr(addr)))
+	data, err := bar.TryRead(addr)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TryRead reads data from a PCIExpress base address register. It returns a
+// *PCIeError wrapping ErrClosed or ErrOutOfRange if the BAR has been closed or
+// the address is out of range.
+func (bar *PCIeBAR) TryRead(addr uint32) (uint32, error) {
+	if err := bar.acquire("read BAR", addr, 4); err != nil {
+		return 0, err
+	}
+	data := *(*uint32)(bar.ptr(addr))
+	bar.release()
+	return data, nil
+}
+
+// acquire registers an access of the specified size at the specified address.
+// It returns an error if the BAR has been closed or the access is out of range.
+// If no error is returned, release must be called after the access.
+func (bar *PCIeBAR) acquire(op string, addr uint32, size int) error {
+	// count the access before checking the closed flag, so that Close either
+	// waits for the access or the access sees the BAR closed
+	bar.active.Add(1)
+	if bar.closed.Load() {
+		bar.active.Add(-1)
+		return newPCIeError(op, bar.devName, ErrClosed)
+	}
+	if uint64(addr)+uint64(size) > uint64(bar.size) {
+		bar.active.Add(-1)
+		return newPCIeError(fmt.Sprintf("%s at 0x%08x", op, addr), bar.devName,
+			ErrOutOfRange)
+	}
+	return nil
+}
+
+// release marks an access registered by acquire as completed.
+func (bar *PCIeBAR) release() {
+	bar.active.Add(-1)
+}
+
+// ptr returns a pointer to the BAR at the specified address. The access must
+// have been registered by acquire.
+func (bar *PCIeBAR) ptr(addr uint32) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(unsafe.Pointer(&bar.bar[0])) + uintptr(addr))
 }
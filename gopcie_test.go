@@ -0,0 +1,321 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tests of resource cleanup and of accesses to closed devices and BARs. A leak
+// checker compares the open file descriptors (/proc/self/fd) and memory
+// mappings (/proc/self/maps) before and after opening and closing devices and
+// BARs.
+//
+
+package gopcie
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// leakChecker records the number of open file descriptors and memory mappings
+// of a file.
+type leakChecker struct {
+	t        *testing.T
+	filename string
+	fds      int
+	maps     int
+}
+
+// newLeakChecker records the current number of open file descriptors and
+// mappings of the file.
+func newLeakChecker(t *testing.T, filename string) *leakChecker {
+	c := &leakChecker{t: t, filename: filename}
+	c.fds, c.maps = c.count()
+	return c
+}
+
+// count returns the number of open file descriptors and mappings of the file.
+func (c *leakChecker) count() (int, int) {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	maps, err := ioutil.ReadFile("/proc/self/maps")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	nMaps := 0
+	if c.filename != "" {
+		nMaps = strings.Count(string(maps), c.filename)
+	}
+	return len(fds), nMaps
+}
+
+// check fails the test if the number of open file descriptors or mappings
+// differs from the recorded one by other than the expected amounts.
+func (c *leakChecker) check(fds, maps int) {
+	c.t.Helper()
+	nFds, nMaps := c.count()
+	if nFds != c.fds+fds {
+		c.t.Errorf("%d open file descriptors, expected %d", nFds, c.fds+fds)
+	}
+	if nMaps != c.maps+maps {
+		c.t.Errorf("%d mappings of %s, expected %d", nMaps, c.filename,
+			c.maps+maps)
+	}
+}
+
+// tempBARFile creates a temporary file of the specified size that is mapped
+// in place of a BAR resource file.
+func tempBARFile(t *testing.T, size int) string {
+	file, err := ioutil.TempFile("", "gopcie-bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	t.Cleanup(func() { os.Remove(file.Name()) })
+	if err := file.Truncate(int64(size)); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func TestPCIeDMACloseLeak(t *testing.T) {
+	leaks := newLeakChecker(t, "")
+
+	dev, err := PCIeDMAOpen("/dev/zero", PCIE_ACCESS_READ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(1, 0)
+
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(0, 0)
+
+	// closing twice has no effect
+	if err := dev.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	leaks.check(0, 0)
+}
+
+func TestPCIeDMAAccessAfterClose(t *testing.T) {
+	dev, err := PCIeDMAOpen("/dev/zero", PCIE_ACCESS_READ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev.Close()
+
+	if err := dev.Read(0, make([]byte, 16)); !errors.Is(err, ErrClosed) {
+		t.Errorf("read after close: %v, expected ErrClosed", err)
+	}
+}
+
+func TestPCIeBARCloseLeak(t *testing.T) {
+	filename := tempBARFile(t, 4096)
+	leaks := newLeakChecker(t, filename)
+
+	bar, err := pcieBAROpenFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(1, 1)
+
+	if err := bar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(0, 0)
+
+	// closing twice has no effect
+	if err := bar.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	leaks.check(0, 0)
+}
+
+func TestPCIeBARMmapFailureLeak(t *testing.T) {
+	// mapping an empty file fails after the file has been opened
+	filename := tempBARFile(t, 0)
+	leaks := newLeakChecker(t, filename)
+
+	if _, err := pcieBAROpenFile(filename); err == nil {
+		t.Fatal("mapping empty file succeeded")
+	}
+	leaks.check(0, 0)
+}
+
+func TestPCIeBARAccessAfterClose(t *testing.T) {
+	bar, err := pcieBAROpenFile(tempBARFile(t, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar.Write(0, 0x12345678)
+	if data := bar.Read(0); data != 0x12345678 {
+		t.Errorf("read 0x%08x, expected 0x12345678", data)
+	}
+	if _, err := bar.TryRead(4096); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("read out of range: %v, expected ErrOutOfRange", err)
+	}
+	if err := bar.TryWrite16(4095, 0); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("16 bit write out of range: %v, expected ErrOutOfRange", err)
+	}
+	bar.Close()
+
+	if size := bar.Size(); size != 0 {
+		t.Errorf("size after close: %d", size)
+	}
+	if _, err := bar.TryRead(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("read after close: %v, expected ErrClosed", err)
+	}
+	if err := bar.TryWrite(0, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after close: %v, expected ErrClosed", err)
+	}
+	if _, err := bar.TryRead64(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("64 bit read after close: %v, expected ErrClosed", err)
+	}
+	if err := bar.TryWriteBlock(0, make([]byte, 8)); !errors.Is(err,
+		ErrClosed) {
+		t.Errorf("block write after close: %v, expected ErrClosed", err)
+	}
+
+	// the panicking accessors panic with a *PCIeError
+	func() {
+		defer func() {
+			var pcieErr *PCIeError
+			err, _ := recover().(error)
+			if !errors.As(err, &pcieErr) || !errors.Is(err, ErrClosed) {
+				t.Errorf("read after close panicked with %v", err)
+			}
+		}()
+		bar.Read64(0)
+	}()
+}
+
+func TestPCIeBARConcurrentClose(t *testing.T) {
+	bar, err := pcieBAROpenFile(tempBARFile(t, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// accesses racing with Close either succeed or fail with ErrClosed, but
+	// never access the unmapped BAR
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				if _, err := bar.TryRead(0); err != nil {
+					if !errors.Is(err, ErrClosed) {
+						t.Errorf("read: %v", err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	if err := bar.Close(); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
+
+func TestPCIeBARBlockAccess(t *testing.T) {
+	bar, err := pcieBAROpenFile(tempBARFile(t, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bar.Close()
+
+	// the block starts and ends with 32 bit accesses
+	data := make([]byte, 20)
+	for i := range data {
+		data[i] = byte(i + 1)
+	}
+	bar.WriteBlock(4, data)
+	if bar.Read(0) != 0 || bar.Read(24) != 0 {
+		t.Error("block write modified surrounding memory")
+	}
+	readData := make([]byte, len(data))
+	bar.ReadBlock(4, readData)
+	if string(readData) != string(data) {
+		t.Errorf("read %v, expected %v", readData, data)
+	}
+
+	// unaligned blocks are rejected
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("unaligned block write panicked with %v", err)
+			}
+		}()
+		bar.WriteBlock(2, data)
+	}()
+}
+
+func TestPCIeRingClosedBAR(t *testing.T) {
+	dev, err := PCIeDMAOpen("/dev/zero", PCIE_ACCESS_READ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	bar, err := pcieBAROpenFile(tempBARFile(t, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pointer registers must be located within the bar
+	cfg := PCIeRingConfig{Size: 4096, HeadReg: 4096, TailReg: 4}
+	if _, err := NewPCIeRing(dev, bar, cfg); !errors.Is(err,
+		ErrInvalidArgument) {
+		t.Errorf("ring with register out of range: %v", err)
+	}
+
+	// ring accesses fail once the bar has been closed
+	cfg.HeadReg = 0
+	ring, err := NewPCIeRing(dev, bar, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar.Close()
+	if _, err := ring.Used(); !errors.Is(err, ErrClosed) {
+		t.Errorf("used after close: %v, expected ErrClosed", err)
+	}
+	_, err = ring.Read(context.Background(), make([]byte, 16))
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("read after close: %v, expected ErrClosed", err)
+	}
+}
@@ -25,7 +25,7 @@
 //   - Andreas Oeldemann <andreas.oeldemann@tum.de>
 //
 // Date Created:        June 9th 2017
-// Date Last Modified:  July 4th 2017
+// Date Last Modified:  October 19th 2026
 //
 // Description:
 //
@@ -36,6 +36,17 @@ package gopcie
 
 import (
 	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// poll(2) event flags
+const (
+	pollIn   = 0x1
+	pollErr  = 0x8
+	pollHup  = 0x10
+	pollNval = 0x20
 )
 
 // HexStringToInt converts a hex string (which may start with a '0x' prefix) to
@@ -50,3 +61,48 @@ func HexStringToInt(hexStr string) (value uint64, err error) {
 	value, err = strconv.ParseUint(hexStr, 16, 64)
 	return value, err
 }
+
+// pollReadable waits until the file descriptor becomes readable or the timeout
+// expires. A negative timeout waits forever. The function returns false if the
+// timeout expired.
+func pollReadable(fd int, timeout time.Duration) (bool, error) {
+	pollFd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{fd: int32(fd), events: pollIn}
+
+	// compute deadline, so that the remaining timeout can be recomputed when
+	// the poll is interrupted
+	deadline := time.Now().Add(timeout)
+
+	for {
+		// a nil timespec pointer blocks forever
+		var ts *syscall.Timespec
+		if timeout >= 0 {
+			remaining := time.Until(deadline)
+			if remaining < 0 {
+				remaining = 0
+			}
+			t := syscall.NsecToTimespec(remaining.Nanoseconds())
+			ts = &t
+		}
+
+		n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL,
+			uintptr(unsafe.Pointer(&pollFd)), 1, uintptr(unsafe.Pointer(ts)),
+			0, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return false, errno
+		}
+		if n == 0 {
+			return false, nil
+		}
+		if (pollFd.revents & (pollErr | pollHup | pollNval)) != 0 {
+			return false, syscall.EIO
+		}
+		return true, nil
+	}
+}
@@ -0,0 +1,305 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Hot-plug watcher. Listens to kernel uevents on a netlink socket and delivers
+// add, remove and change events of PCIExpress devices, so that applications
+// can close and reopen their devices when a card is removed, reset or
+// rescanned.
+//
+
+package gopcie
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	PCIE_HOTPLUG_ADD    = 1
+	PCIE_HOTPLUG_REMOVE = 2
+	PCIE_HOTPLUG_CHANGE = 3
+	PCIE_HOTPLUG_BIND   = 4
+	PCIE_HOTPLUG_UNBIND = 5
+)
+
+// netlink uevent constants
+const (
+	netlinkKobjectUevent   = 15
+	netlinkUeventGroup     = 1
+	netlinkUeventBufSize   = 8192
+	hotplugPollInterval    = 100 * time.Millisecond
+	hotplugUeventSubsysPCI = "pci"
+)
+
+// PCIeHotplugEvent is a hot-plug event of a PCIExpress device.
+type PCIeHotplugEvent struct {
+	Action   int    // PCIE_HOTPLUG_ADD, PCIE_HOTPLUG_REMOVE, ...
+	Addr     string // PCI address (e.g. "0000:01:00.0")
+	VendorId uint
+	DeviceId uint
+	Driver   string // driver bound to the device (empty if none)
+}
+
+// PCIeHotplugFilter selects the devices for which events are delivered. Zero
+// values match any device.
+type PCIeHotplugFilter struct {
+	VendorId uint
+	DeviceId uint
+	Addr     string
+}
+
+// PCIeHotplugWatcher delivers hot-plug events of PCIExpress devices.
+type PCIeHotplugWatcher struct {
+	fd     int
+	filter PCIeHotplugFilter
+	events chan PCIeHotplugEvent
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+	missed atomic.Uint64
+
+	// error that ended the receive loop
+	runMutex sync.Mutex
+	runErr   error
+}
+
+// PCIeHotplugWatch starts watching for hot-plug events of the PCIExpress
+// devices matching the filter. Events are delivered on a channel with the
+// specified buffer size. Receiving events never blocks: if the channel is full
+// when an event arrives, the event is dropped and counted as missed (see
+// Missed). Applications that must not miss events should use a buffer that is
+// large enough and rescan the devices when Missed increases.
+func PCIeHotplugWatch(filter PCIeHotplugFilter,
+	bufSize int) (*PCIeHotplugWatcher, error) {
+	// open netlink socket
+	fd, err := syscall.Socket(syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, netlinkKobjectUevent)
+	if err != nil {
+		return nil, newPCIeError("open netlink socket", "", err)
+	}
+
+	// subscribe to kernel uevents
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: netlinkUeventGroup,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, newPCIeError("bind netlink socket", "", err)
+	}
+
+	watcher := &PCIeHotplugWatcher{
+		fd:     fd,
+		filter: filter,
+		events: make(chan PCIeHotplugEvent, bufSize),
+		stop:   make(chan struct{}),
+	}
+
+	watcher.wg.Add(1)
+	go watcher.run()
+
+	return watcher, nil
+}
+
+// Events returns the channel on which events are delivered. The channel is
+// closed when the watcher is closed or receiving events fails (see Err).
+func (watcher *PCIeHotplugWatcher) Events() <-chan PCIeHotplugEvent {
+	return watcher.events
+}
+
+// Missed returns the number of events that were dropped, either because the
+// channel was full or because the kernel's socket buffer overflowed. In the
+// latter case the number of dropped events is unknown and each overflow is
+// counted as one missed event.
+func (watcher *PCIeHotplugWatcher) Missed() uint64 {
+	return watcher.missed.Load()
+}
+
+// Err returns the error that ended the delivery of events once the events
+// channel has been closed because receiving events failed. It returns nil
+// while events are delivered and if the watcher has been closed.
+func (watcher *PCIeHotplugWatcher) Err() error {
+	watcher.runMutex.Lock()
+	defer watcher.runMutex.Unlock()
+	return watcher.runErr
+}
+
+// Close stops watching for hot-plug events. Closing an already closed watcher
+// has no effect.
+func (watcher *PCIeHotplugWatcher) Close() error {
+	watcher.once.Do(func() {
+		close(watcher.stop)
+		watcher.wg.Wait()
+		if err := syscall.Close(watcher.fd); err != nil {
+			watcher.err = newPCIeError("close netlink socket", "", err)
+		}
+	})
+	return watcher.err
+}
+
+// run receives uevents until the watcher is closed.
+func (watcher *PCIeHotplugWatcher) run() {
+	defer watcher.wg.Done()
+	defer close(watcher.events)
+
+	buf := make([]byte, netlinkUeventBufSize)
+	for {
+		select {
+		case <-watcher.stop:
+			return
+		default:
+		}
+
+		// wait for uevent
+		ready, err := pollReadable(watcher.fd, hotplugPollInterval)
+		if err != nil {
+			watcher.fail(newPCIeError("wait for uevent", "", err))
+			return
+		}
+		if !ready {
+			continue
+		}
+
+		// receive uevent
+		n, _, err := syscall.Recvfrom(watcher.fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ENOBUFS {
+			// uevents were dropped by the kernel because the socket buffer
+			// overflowed. continue with the next one
+			watcher.missed.Add(1)
+			continue
+		}
+		if err != nil {
+			watcher.fail(newPCIeError("receive uevent", "", err))
+			return
+		}
+
+		// parse uevent and deliver it if it matches the filter
+		event, ok := parsePCIeUevent(buf[:n])
+		if !ok || !watcher.filter.matches(event) {
+			continue
+		}
+		select {
+		case watcher.events <- event:
+		default:
+			watcher.missed.Add(1)
+		}
+	}
+}
+
+// fail records the error that ends the receive loop.
+func (watcher *PCIeHotplugWatcher) fail(err error) {
+	watcher.runMutex.Lock()
+	watcher.runErr = err
+	watcher.runMutex.Unlock()
+}
+
+// matches returns true if the event matches the filter.
+func (filter PCIeHotplugFilter) matches(event PCIeHotplugEvent) bool {
+	if filter.VendorId != 0 && filter.VendorId != event.VendorId {
+		return false
+	}
+	if filter.DeviceId != 0 && filter.DeviceId != event.DeviceId {
+		return false
+	}
+	if filter.Addr != "" && filter.Addr != event.Addr {
+		return false
+	}
+	return true
+}
+
+// parsePCIeUevent parses a kernel uevent message. The message consists of a
+// "<action>@<devpath>" header followed by "KEY=VALUE" pairs, all terminated by
+// zero bytes. The function returns false if the message is not a PCIExpress
+// device event.
+func parsePCIeUevent(msg []byte) (PCIeHotplugEvent, bool) {
+	var event PCIeHotplugEvent
+
+	fields := bytes.Split(msg, []byte{0})
+
+	// the first field must be the header. messages sent by udev start with
+	// "libudev" and are not handled
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		return event, false
+	}
+
+	vars := make(map[string]string)
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) == 2 {
+			vars[kv[0]] = kv[1]
+		}
+	}
+
+	if vars["SUBSYSTEM"] != hotplugUeventSubsysPCI {
+		return event, false
+	}
+
+	switch vars["ACTION"] {
+	case "add":
+		event.Action = PCIE_HOTPLUG_ADD
+	case "remove":
+		event.Action = PCIE_HOTPLUG_REMOVE
+	case "change":
+		event.Action = PCIE_HOTPLUG_CHANGE
+	case "bind":
+		event.Action = PCIE_HOTPLUG_BIND
+	case "unbind":
+		event.Action = PCIE_HOTPLUG_UNBIND
+	default:
+		return event, false
+	}
+
+	event.Addr = vars["PCI_SLOT_NAME"]
+	event.Driver = vars["DRIVER"]
+
+	// PCI_ID has the format "<vendor id>:<device id>"
+	ids := strings.SplitN(vars["PCI_ID"], ":", 2)
+	if len(ids) == 2 {
+		vendorId, err := HexStringToInt(ids[0])
+		if err == nil {
+			event.VendorId = uint(vendorId)
+		}
+		deviceId, err := HexStringToInt(ids[1])
+		if err == nil {
+			event.DeviceId = uint(deviceId)
+		}
+	}
+
+	return event, event.Addr != ""
+}
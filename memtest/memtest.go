@@ -0,0 +1,417 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Package memtest tests card memory (e.g. on-card DDR) via DMA transfers. A
+// memory range is written with a test pattern, read back and compared. Errors
+// are reported per region of the tested range. Pseudo-random patterns are
+// derived from a seed, so that test runs are reproducible.
+//
+
+package memtest
+
+import (
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"time"
+)
+
+// Test patterns.
+const (
+	// PatternWalkingOnes writes 64 bit words with a single bit set, the set
+	// bit of neighbouring words differing by one position. In 64 passes over
+	// the tested range, the set bit walks through all bit positions of every
+	// word.
+	PatternWalkingOnes = "walking-ones"
+	// PatternWalkingZeros writes 64 bit words with a single bit cleared like
+	// PatternWalkingOnes, the cleared bit walking through all bit positions
+	// of every word in 64 passes.
+	PatternWalkingZeros = "walking-zeros"
+	// PatternAddress writes the card address of each 64 bit word to the word
+	// and its inverse in a second pass.
+	PatternAddress = "address"
+	// PatternPRBS writes a PRBS-31 sequence.
+	PatternPRBS = "prbs"
+	// PatternRandom writes, reads back and compares random data at random
+	// addresses.
+	PatternRandom = "random"
+	// PatternRetention writes a PRBS-31 sequence and verifies it after the
+	// retention delay.
+	PatternRetention = "retention"
+)
+
+// Patterns lists all test patterns in the order they are run by default.
+var Patterns = []string{
+	PatternWalkingOnes,
+	PatternWalkingZeros,
+	PatternAddress,
+	PatternPRBS,
+	PatternRandom,
+	PatternRetention,
+}
+
+// wordSize is the size of the words written by the test patterns.
+const wordSize = 8
+
+// Default configuration values.
+const (
+	defaultChunkSize      = 1 << 20
+	defaultRandomAccesses = 1024
+	defaultRetentionDelay = 10 * time.Second
+)
+
+// Memory is card memory accessed via DMA transfers, e.g. *gopcie.PCIeDMA or
+// *gopcie.PCIeDMAMultiChannel.
+type Memory interface {
+	Write(addr uint64, data []byte) error
+	Read(addr uint64, data []byte) error
+}
+
+// Config holds the configuration of a memory test.
+type Config struct {
+	Addr uint64 // card address of the tested range (multiple of 8)
+	Size uint64 // size of the tested range (multiple of 8)
+
+	// ChunkSize is the size of the DMA transfers (multiple of 8). Defaults
+	// to 1 MByte.
+	ChunkSize int
+
+	// RegionSize is the size of the regions errors are reported for.
+	// Defaults to the size of the tested range.
+	RegionSize uint64
+
+	// Patterns selects the test patterns. Defaults to all patterns.
+	Patterns []string
+
+	// Seed is the seed of the pseudo-random patterns.
+	Seed int64
+
+	// RandomAccesses is the number of accesses of the random pattern.
+	// Defaults to 1024.
+	RandomAccesses int
+
+	// RetentionDelay is the time the retention pattern waits before
+	// verifying the written data. Defaults to 10 seconds if nil, a delay of
+	// zero verifies the data right away.
+	RetentionDelay *time.Duration
+
+	// BufferPool provides the transfer buffers, e.g. the pool of the DMA
+	// device (see PCIeDMA.BufferPool). Defaults to buffers allocated by the
+	// go runtime.
+	BufferPool *gopcie.PCIeDMABufferPool
+}
+
+// Mismatch is a mismatching 64 bit word.
+type Mismatch struct {
+	Addr     uint64 // card address
+	Expected uint64 // expected value
+	Actual   uint64 // value read from card memory
+}
+
+// Region holds the errors detected in a region of the tested range.
+type Region struct {
+	Addr   uint64    // card address of the region
+	Size   uint64    // size of the region
+	Errors uint64    // number of mismatching 64 bit words
+	First  *Mismatch // first mismatching word, nil if there is none
+}
+
+// Result holds the result of a test pattern.
+type Result struct {
+	Pattern  string
+	Errors   uint64   // number of mismatching 64 bit words
+	Regions  []Region // errors per region
+	Duration time.Duration
+}
+
+// Run runs the selected test patterns on the card memory. It returns one
+// result per pattern. An error is returned if the configuration is invalid or
+// a DMA transfer failed.
+func Run(mem Memory, cfg Config) ([]Result, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	// the transfer buffers are shared by all patterns
+	bufs, err := newBuffers(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer bufs.release()
+
+	var results []Result
+	for _, pattern := range cfg.Patterns {
+		result, err := runPattern(mem, &cfg, bufs, pattern)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// setDefaults validates the configuration and sets default values.
+func (cfg *Config) setDefaults() error {
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.RegionSize == 0 {
+		cfg.RegionSize = cfg.Size
+	}
+	if len(cfg.Patterns) == 0 {
+		cfg.Patterns = Patterns
+	}
+	if cfg.RandomAccesses == 0 {
+		cfg.RandomAccesses = defaultRandomAccesses
+	}
+	if cfg.RetentionDelay == nil {
+		retentionDelay := defaultRetentionDelay
+		cfg.RetentionDelay = &retentionDelay
+	}
+
+	if cfg.Size == 0 || cfg.Addr%wordSize != 0 || cfg.Size%wordSize != 0 ||
+		cfg.ChunkSize <= 0 || cfg.ChunkSize%wordSize != 0 ||
+		cfg.RandomAccesses < 0 || *cfg.RetentionDelay < 0 {
+		return fmt.Errorf("memtest: %w", gopcie.ErrInvalidArgument)
+	}
+	for _, pattern := range cfg.Patterns {
+		if !isPattern(pattern) {
+			return fmt.Errorf("memtest: unknown pattern %s: %w", pattern,
+				gopcie.ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// isPattern returns true if the name is one of the test patterns.
+func isPattern(name string) bool {
+	for _, pattern := range Patterns {
+		if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// buffers holds the transfer buffers of the expected and the actual data.
+type buffers struct {
+	pool     *gopcie.PCIeDMABufferPool
+	pooled   []*gopcie.PCIeDMABuffer
+	expected []byte
+	actual   []byte
+}
+
+// newBuffers allocates the transfer buffers, taking them from the configured
+// buffer pool if there is one.
+func newBuffers(cfg *Config) (*buffers, error) {
+	bufs := &buffers{pool: cfg.BufferPool}
+	if bufs.pool == nil {
+		bufs.expected = make([]byte, cfg.ChunkSize)
+		bufs.actual = make([]byte, cfg.ChunkSize)
+		return bufs, nil
+	}
+
+	for _, data := range []*[]byte{&bufs.expected, &bufs.actual} {
+		buf, err := bufs.pool.Get(uint64(cfg.ChunkSize))
+		if err != nil {
+			bufs.release()
+			return nil, err
+		}
+		bufs.pooled = append(bufs.pooled, buf)
+		*data = buf.Bytes()[:cfg.ChunkSize]
+	}
+	return bufs, nil
+}
+
+// release returns pooled transfer buffers to the pool.
+func (bufs *buffers) release() {
+	for _, buf := range bufs.pooled {
+		bufs.pool.Put(buf)
+	}
+	bufs.pooled = nil
+}
+
+// runPattern runs a single test pattern.
+func runPattern(mem Memory, cfg *Config, bufs *buffers,
+	pattern string) (Result, error) {
+	startTime := time.Now()
+	t := newTest(mem, cfg, bufs, pattern)
+
+	var err error
+	switch pattern {
+	case PatternWalkingOnes:
+		for shift := uint64(0); shift < 64 && err == nil; shift++ {
+			err = t.runSequential(newWalkingOnesFunc(shift), 0)
+		}
+	case PatternWalkingZeros:
+		for shift := uint64(0); shift < 64 && err == nil; shift++ {
+			err = t.runSequential(newWalkingZerosFunc(shift), 0)
+		}
+	case PatternRandom:
+		err = t.runRandom()
+	case PatternRetention:
+		// derive a sequence different from the prbs pattern, so that data
+		// left over from it is not mistaken for retained data
+		err = t.runSequential(newPRBSFunc(^cfg.Seed), *cfg.RetentionDelay)
+	case PatternAddress:
+		err = t.runSequential(addressFunc, 0)
+		if err == nil {
+			err = t.runSequential(invertedAddressFunc, 0)
+		}
+	case PatternPRBS:
+		err = t.runSequential(newPRBSFunc(cfg.Seed), 0)
+	}
+
+	t.result.Duration = time.Since(startTime)
+	return t.result, err
+}
+
+// test holds the state of a running test pattern.
+type test struct {
+	mem    Memory
+	cfg    *Config
+	result Result
+
+	expected []byte
+	actual   []byte
+}
+
+// newTest creates a test of a pattern.
+func newTest(mem Memory, cfg *Config, bufs *buffers, pattern string) *test {
+	t := &test{
+		mem:      mem,
+		cfg:      cfg,
+		result:   Result{Pattern: pattern},
+		expected: bufs.expected,
+		actual:   bufs.actual,
+	}
+
+	// divide tested range into regions
+	for addr := cfg.Addr; addr < cfg.Addr+cfg.Size; addr += cfg.RegionSize {
+		size := cfg.RegionSize
+		if addr+size > cfg.Addr+cfg.Size {
+			size = cfg.Addr + cfg.Size - addr
+		}
+		t.result.Regions = append(t.result.Regions,
+			Region{Addr: addr, Size: size})
+	}
+	return t
+}
+
+// runSequential writes the pattern to the tested range chunk by chunk, waits
+// for the specified delay, then reads the range back and compares it. The
+// pattern function is reset before each pass.
+func (t *test) runSequential(pattern patternFunc, delay time.Duration) error {
+	for pass := 0; pass < 2; pass++ {
+		fill := pattern()
+		for addr := t.cfg.Addr; addr < t.cfg.Addr+t.cfg.Size; {
+			size := uint64(t.cfg.ChunkSize)
+			if addr+size > t.cfg.Addr+t.cfg.Size {
+				size = t.cfg.Addr + t.cfg.Size - addr
+			}
+			expected := t.expected[:size]
+			fill(addr, expected)
+
+			if pass == 0 {
+				if err := t.mem.Write(addr, expected); err != nil {
+					return err
+				}
+			} else {
+				actual := t.actual[:size]
+				if err := t.mem.Read(addr, actual); err != nil {
+					return err
+				}
+				t.compare(addr, expected, actual)
+			}
+			addr += size
+		}
+
+		if pass == 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+	return nil
+}
+
+// runRandom writes random data to random addresses, reads it back right away
+// and compares it.
+func (t *test) runRandom() error {
+	rng := newRand(t.cfg.Seed)
+	nWords := t.cfg.Size / wordSize
+	maxWords := uint64(t.cfg.ChunkSize) / wordSize
+
+	for i := 0; i < t.cfg.RandomAccesses; i++ {
+		// choose random word-aligned range
+		word := rng.Uint64() % nWords
+		n := 1 + rng.Uint64()%maxWords
+		if word+n > nWords {
+			n = nWords - word
+		}
+		addr := t.cfg.Addr + word*wordSize
+		expected := t.expected[:n*wordSize]
+		actual := t.actual[:n*wordSize]
+		for offset := 0; offset < len(expected); offset += wordSize {
+			putWord(expected[offset:], rng.Uint64())
+		}
+
+		if err := t.mem.Write(addr, expected); err != nil {
+			return err
+		}
+		if err := t.mem.Read(addr, actual); err != nil {
+			return err
+		}
+		t.compare(addr, expected, actual)
+	}
+	return nil
+}
+
+// compare compares card memory read from addr against the expected data word
+// by word and records mismatches in the regions.
+func (t *test) compare(addr uint64, expected, actual []byte) {
+	for offset := 0; offset < len(expected); offset += wordSize {
+		expectedWord := getWord(expected[offset:])
+		actualWord := getWord(actual[offset:])
+		if expectedWord == actualWord {
+			continue
+		}
+
+		wordAddr := addr + uint64(offset)
+		region := &t.result.Regions[(wordAddr-t.cfg.Addr)/t.cfg.RegionSize]
+		region.Errors++
+		if region.First == nil {
+			region.First = &Mismatch{
+				Addr:     wordAddr,
+				Expected: expectedWord,
+				Actual:   actualWord,
+			}
+		}
+		t.result.Errors++
+	}
+}
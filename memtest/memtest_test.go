@@ -0,0 +1,238 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tests of the memory test patterns against card memory emulated in go
+// memory. Faults are injected by flipping or clearing chosen bits of words
+// read back from the emulated memory.
+//
+
+package memtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// memFake emulates card memory starting at a card address. Bits set in the
+// flip mask of a word are inverted, bits set in its stuck-at-zero mask are
+// cleared when the word is read.
+type memFake struct {
+	addr      uint64
+	data      []byte
+	flip      map[uint64]uint64
+	stuckZero map[uint64]uint64
+}
+
+// newMemFake creates emulated card memory of the specified size.
+func newMemFake(addr, size uint64) *memFake {
+	return &memFake{
+		addr:      addr,
+		data:      make([]byte, size),
+		flip:      make(map[uint64]uint64),
+		stuckZero: make(map[uint64]uint64),
+	}
+}
+
+func (mem *memFake) Write(addr uint64, data []byte) error {
+	copy(mem.data[addr-mem.addr:], data)
+	return nil
+}
+
+func (mem *memFake) Read(addr uint64, data []byte) error {
+	copy(data, mem.data[addr-mem.addr:])
+	for offset := 0; offset < len(data); offset += wordSize {
+		wordAddr := addr + uint64(offset)
+		word := getWord(data[offset:])
+		word ^= mem.flip[wordAddr]
+		word &^= mem.stuckZero[wordAddr]
+		putWord(data[offset:], word)
+	}
+	return nil
+}
+
+// testConfig returns the configuration of a test of 60 KByte in regions of 16
+// KByte, the last region being 12 KByte. Transfers of 3000 bytes cross region
+// boundaries.
+func testConfig(pattern string) Config {
+	retentionDelay := time.Duration(0)
+	return Config{
+		Addr:           0x10000,
+		Size:           60 << 10,
+		ChunkSize:      3000,
+		RegionSize:     16 << 10,
+		Patterns:       []string{pattern},
+		Seed:           42,
+		RetentionDelay: &retentionDelay,
+	}
+}
+
+// runTestPattern runs a single pattern on the memory and returns its result.
+func runTestPattern(t *testing.T, mem Memory, cfg Config) Result {
+	t.Helper()
+	results, err := Run(mem, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d results, expected 1", len(results))
+	}
+	return results[0]
+}
+
+func TestPatternsNoFault(t *testing.T) {
+	for _, pattern := range Patterns {
+		cfg := testConfig(pattern)
+		result := runTestPattern(t, newMemFake(cfg.Addr, cfg.Size), cfg)
+		if result.Errors != 0 {
+			t.Errorf("%s: %d errors in fault-free memory", pattern,
+				result.Errors)
+		}
+
+		// regions cover the tested range
+		if len(result.Regions) != 4 {
+			t.Fatalf("%s: %d regions, expected 4", pattern,
+				len(result.Regions))
+		}
+		for i, region := range result.Regions {
+			size := uint64(16 << 10)
+			if i == 3 {
+				size = 12 << 10
+			}
+			if region.Addr != cfg.Addr+uint64(i)*(16<<10) ||
+				region.Size != size {
+				t.Errorf("%s: region %d at 0x%x of size 0x%x", pattern, i,
+					region.Addr, region.Size)
+			}
+		}
+	}
+}
+
+func TestPatternsDetectFlippedBit(t *testing.T) {
+	// number of times each pattern reads the faulty word
+	expectedErrors := map[string]uint64{
+		PatternWalkingOnes:  64,
+		PatternWalkingZeros: 64,
+		PatternAddress:      2,
+		PatternPRBS:         1,
+		PatternRetention:    1,
+	}
+
+	for _, pattern := range Patterns {
+		cfg := testConfig(pattern)
+		mem := newMemFake(cfg.Addr, cfg.Size)
+
+		// the faulty word is the first one of the third region and located
+		// in a transfer starting in the second region
+		faultAddr := cfg.Addr + 0x8000
+		mem.flip[faultAddr] = 1 << 5
+
+		result := runTestPattern(t, mem, cfg)
+		if result.Errors == 0 {
+			t.Errorf("%s: flipped bit not detected", pattern)
+			continue
+		}
+		if expected, ok := expectedErrors[pattern]; ok &&
+			result.Errors != expected {
+			t.Errorf("%s: %d errors, expected %d", pattern, result.Errors,
+				expected)
+		}
+		for i, region := range result.Regions {
+			if i != 2 {
+				if region.Errors != 0 || region.First != nil {
+					t.Errorf("%s: %d errors in region %d", pattern,
+						region.Errors, i)
+				}
+				continue
+			}
+			if region.Errors != result.Errors {
+				t.Errorf("%s: %d errors in region %d, expected %d", pattern,
+					region.Errors, i, result.Errors)
+			}
+			first := region.First
+			if first == nil || first.Addr != faultAddr ||
+				first.Actual != first.Expected^(1<<5) {
+				t.Errorf("%s: first mismatch %+v, expected at 0x%x", pattern,
+					first, faultAddr)
+			}
+		}
+	}
+}
+
+func TestWalkingPatternsDetectStuckBit(t *testing.T) {
+	// a bit stuck at zero is expected to be set in one pass of the walking
+	// ones and in all but one pass of the walking zeros
+	for pattern, expected := range map[string]uint64{
+		PatternWalkingOnes:  1,
+		PatternWalkingZeros: 63,
+	} {
+		cfg := testConfig(pattern)
+		mem := newMemFake(cfg.Addr, cfg.Size)
+		mem.stuckZero[cfg.Addr+0x100] = 1 << 63
+
+		result := runTestPattern(t, mem, cfg)
+		if result.Errors != expected {
+			t.Errorf("%s: %d errors, expected %d", pattern, result.Errors,
+				expected)
+		}
+	}
+}
+
+func TestPRBS(t *testing.T) {
+	// the sequence does not depend on the transfer size, i.e. the write and
+	// read pass produce the same data as long as they use the same chunks
+	var written [][]byte
+	for _, chunkSize := range []int{3000, 8 << 10} {
+		cfg := testConfig(PatternPRBS)
+		cfg.ChunkSize = chunkSize
+		mem := newMemFake(cfg.Addr, cfg.Size)
+		if result := runTestPattern(t, mem, cfg); result.Errors != 0 {
+			t.Errorf("%d errors with chunk size %d", result.Errors, chunkSize)
+		}
+		written = append(written, mem.data)
+	}
+	if !bytes.Equal(written[0], written[1]) {
+		t.Error("sequence depends on the transfer size")
+	}
+
+	// the sequence is not constant and depends on the seed
+	if bytes.Equal(written[0][:len(written[0])/2],
+		written[0][len(written[0])/2:]) {
+		t.Error("sequence repeats within the tested range")
+	}
+	cfg := testConfig(PatternPRBS)
+	cfg.Seed = 43
+	mem := newMemFake(cfg.Addr, cfg.Size)
+	runTestPattern(t, mem, cfg)
+	if bytes.Equal(mem.data, written[0]) {
+		t.Error("sequence does not depend on the seed")
+	}
+}
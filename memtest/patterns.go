@@ -0,0 +1,132 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Test pattern generators. A pattern fills a buffer with the data expected at
+// a card address. Since the tested range is written and read back in the same
+// chunk order, stateful generators (PRBS) produce identical data in both
+// passes.
+//
+
+package memtest
+
+import (
+	"encoding/binary"
+	"math/rand"
+)
+
+// patternFunc returns a function that fills a buffer with the pattern data of
+// the card address. Each call starts a new pass over the tested range.
+type patternFunc func() func(addr uint64, buf []byte)
+
+// newWalkingOnesFunc returns a generator setting bit ((word index + shift) mod
+// 64) in each word. Running it with the shifts 0 to 63 walks the set bit
+// through all bit positions of every word.
+func newWalkingOnesFunc(shift uint64) patternFunc {
+	return func() func(addr uint64, buf []byte) {
+		return func(addr uint64, buf []byte) {
+			for offset := 0; offset < len(buf); offset += wordSize {
+				word := (addr + uint64(offset)) / wordSize
+				putWord(buf[offset:], 1<<((word+shift)%64))
+			}
+		}
+	}
+}
+
+// newWalkingZerosFunc returns a generator clearing bit ((word index + shift)
+// mod 64) in each word.
+func newWalkingZerosFunc(shift uint64) patternFunc {
+	return func() func(addr uint64, buf []byte) {
+		return func(addr uint64, buf []byte) {
+			for offset := 0; offset < len(buf); offset += wordSize {
+				word := (addr + uint64(offset)) / wordSize
+				putWord(buf[offset:], ^uint64(1<<((word+shift)%64)))
+			}
+		}
+	}
+}
+
+// addressFunc writes the card address of each word to the word.
+func addressFunc() func(addr uint64, buf []byte) {
+	return func(addr uint64, buf []byte) {
+		for offset := 0; offset < len(buf); offset += wordSize {
+			putWord(buf[offset:], addr+uint64(offset))
+		}
+	}
+}
+
+// invertedAddressFunc writes the inverted card address of each word to the
+// word.
+func invertedAddressFunc() func(addr uint64, buf []byte) {
+	return func(addr uint64, buf []byte) {
+		for offset := 0; offset < len(buf); offset += wordSize {
+			putWord(buf[offset:], ^(addr + uint64(offset)))
+		}
+	}
+}
+
+// newPRBSFunc returns a PRBS-31 (x^31 + x^28 + 1) generator. The generator
+// state is initialized from the seed at the start of each pass.
+func newPRBSFunc(seed int64) patternFunc {
+	return func() func(addr uint64, buf []byte) {
+		// the state must not be zero
+		state := uint32(seed) & 0x7fffffff
+		if state == 0 {
+			state = 1
+		}
+
+		return func(addr uint64, buf []byte) {
+			for i := range buf {
+				var b byte
+				for bit := 0; bit < 8; bit++ {
+					newBit := ((state >> 30) ^ (state >> 27)) & 1
+					state = ((state << 1) | newBit) & 0x7fffffff
+					b = (b << 1) | byte(newBit)
+				}
+				buf[i] = b
+			}
+		}
+	}
+}
+
+// newRand returns the random number generator of the random pattern.
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// putWord stores a 64 bit word in little endian byte order.
+func putWord(buf []byte, word uint64) {
+	binary.LittleEndian.PutUint64(buf, word)
+}
+
+// getWord loads a 64 bit word in little endian byte order.
+func getWord(buf []byte) uint64 {
+	return binary.LittleEndian.Uint64(buf)
+}
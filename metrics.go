@@ -0,0 +1,253 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Transfer metrics. Once enabled for a DMA device, counters of operations,
+// bytes, errors and short transfers as well as latency histograms per transfer
+// size class are kept for reads and writes. The metrics of all devices with
+// enabled metrics are published via expvar in the "gopcie" map, keyed by device
+// path.
+//
+
+package gopcie
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PCIeDMASizeClasses are the upper bounds (in bytes) of the transfer size
+// classes latency histograms are kept for. Transfers larger than the last
+// bound are recorded in an additional class. The bounds are copied when
+// metrics are enabled, changing them only affects devices whose metrics are
+// enabled afterwards. They must not be changed concurrently with enabling
+// metrics.
+var PCIeDMASizeClasses = []int{4 << 10, 64 << 10, 1 << 20, 16 << 20}
+
+// PCIeDMALatencyBuckets are the upper bounds of the latency histogram buckets.
+// Latencies larger than the last bound are recorded in an additional bucket.
+// Like PCIeDMASizeClasses, the bounds are copied when metrics are enabled.
+var PCIeDMALatencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// PCIeDMALatencyHistogram is the latency histogram of a transfer size class.
+type PCIeDMALatencyHistogram struct {
+	MaxSize int             // upper bound of the size class, 0 if unbounded
+	Bounds  []time.Duration // upper bounds of all but the last bucket
+	Buckets []uint64        // transfers per bucket
+	Count   uint64          // number of transfers
+	Sum     time.Duration   // sum of all latencies
+}
+
+// PCIeDMAOpMetrics holds the metrics of either reads or writes.
+type PCIeDMAOpMetrics struct {
+	Operations     uint64                    // number of transfers
+	Bytes          uint64                    // number of bytes transferred
+	Errors         uint64                    // number of failed transfers
+	ShortTransfers uint64                    // number of short transfers
+	Latency        []PCIeDMALatencyHistogram // histograms per size class
+}
+
+// PCIeDMAMetrics holds the metrics of a DMA device.
+type PCIeDMAMetrics struct {
+	Read  PCIeDMAOpMetrics
+	Write PCIeDMAOpMetrics
+}
+
+// pcieDMAMetrics collects the metrics of a DMA device.
+type pcieDMAMetrics struct {
+	mutex     sync.Mutex
+	metrics   PCIeDMAMetrics
+	expvarKey string
+
+	// copies of the size class and latency bucket bounds taken when metrics
+	// were enabled
+	sizeClasses    []int
+	latencyBuckets []time.Duration
+}
+
+// pcieExpvarMetrics is the expvar map the metrics are published in.
+var (
+	pcieExpvarMetrics     *expvar.Map
+	pcieExpvarMetricsOnce sync.Once
+)
+
+// EnableMetrics starts collecting transfer metrics for the device and
+// publishes them via expvar. Enabling metrics of a device with enabled metrics
+// has no effect.
+func (dev *PCIeDMA) EnableMetrics() {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if dev.metrics != nil || dev.fd == nil {
+		return
+	}
+
+	metrics := &pcieDMAMetrics{
+		sizeClasses:    append([]int(nil), PCIeDMASizeClasses...),
+		latencyBuckets: append([]time.Duration(nil), PCIeDMALatencyBuckets...),
+	}
+	metrics.metrics.Read.Latency = metrics.newHistograms()
+	metrics.metrics.Write.Latency = metrics.newHistograms()
+	dev.metrics = metrics
+
+	// publish metrics. if the device has been opened multiple times, the
+	// device path is suffixed with a counter
+	pcieExpvarMetricsOnce.Do(func() {
+		pcieExpvarMetrics = expvar.NewMap("gopcie")
+	})
+	metrics.expvarKey = dev.devName
+	for i := 2; pcieExpvarMetrics.Get(metrics.expvarKey) != nil; i++ {
+		metrics.expvarKey = fmt.Sprintf("%s#%d", dev.devName, i)
+	}
+	pcieExpvarMetrics.Set(metrics.expvarKey, expvar.Func(func() interface{} {
+		return metrics.snapshot()
+	}))
+}
+
+// Metrics returns a snapshot of the transfer metrics of the device. If metrics
+// are not enabled, zero values are returned.
+func (dev *PCIeDMA) Metrics() PCIeDMAMetrics {
+	dev.mutex.RLock()
+	metrics := dev.metrics
+	dev.mutex.RUnlock()
+
+	if metrics == nil {
+		return PCIeDMAMetrics{}
+	}
+	return metrics.snapshot()
+}
+
+// disableMetrics stops collecting metrics and removes them from expvar. The
+// caller must hold the device mutex.
+func (dev *PCIeDMA) disableMetrics() {
+	if dev.metrics == nil {
+		return
+	}
+	pcieExpvarMetrics.Delete(dev.metrics.expvarKey)
+	dev.metrics = nil
+}
+
+// recordTransfer records a transfer in the device's metrics if they are
+// enabled.
+func (dev *PCIeDMA) recordTransfer(op string, size, transferred int, err error,
+	latency time.Duration) {
+	dev.mutex.RLock()
+	metrics := dev.metrics
+	dev.mutex.RUnlock()
+
+	if metrics != nil {
+		metrics.record(op, size, transferred, err, latency)
+	}
+}
+
+// record records a transfer.
+func (metrics *pcieDMAMetrics) record(op string, size, transferred int,
+	err error, latency time.Duration) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	opMetrics := &metrics.metrics.Read
+	if op == "write" {
+		opMetrics = &metrics.metrics.Write
+	}
+
+	opMetrics.Operations++
+	opMetrics.Bytes += uint64(transferred)
+	if err != nil {
+		opMetrics.Errors++
+		if errors.Is(err, ErrShortTransfer) {
+			opMetrics.ShortTransfers++
+		}
+	}
+
+	// find size class and latency bucket
+	class := len(metrics.sizeClasses)
+	for i, maxSize := range metrics.sizeClasses {
+		if size <= maxSize {
+			class = i
+			break
+		}
+	}
+	bucket := len(metrics.latencyBuckets)
+	for i, maxLatency := range metrics.latencyBuckets {
+		if latency <= maxLatency {
+			bucket = i
+			break
+		}
+	}
+
+	histogram := &opMetrics.Latency[class]
+	histogram.Buckets[bucket]++
+	histogram.Count++
+	histogram.Sum += latency
+}
+
+// snapshot returns a copy of the metrics.
+func (metrics *pcieDMAMetrics) snapshot() PCIeDMAMetrics {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	snapshot := metrics.metrics
+	for _, opMetrics := range []*PCIeDMAOpMetrics{&snapshot.Read,
+		&snapshot.Write} {
+		latency := make([]PCIeDMALatencyHistogram, len(opMetrics.Latency))
+		for i, histogram := range opMetrics.Latency {
+			latency[i] = histogram
+			latency[i].Bounds = append([]time.Duration(nil),
+				histogram.Bounds...)
+			latency[i].Buckets = append([]uint64(nil), histogram.Buckets...)
+		}
+		opMetrics.Latency = latency
+	}
+	return snapshot
+}
+
+// newHistograms creates empty latency histograms for all transfer size classes.
+func (metrics *pcieDMAMetrics) newHistograms() []PCIeDMALatencyHistogram {
+	histograms := make([]PCIeDMALatencyHistogram, len(metrics.sizeClasses)+1)
+	for i := range histograms {
+		if i < len(metrics.sizeClasses) {
+			histograms[i].MaxSize = metrics.sizeClasses[i]
+		}
+		histograms[i].Bounds = metrics.latencyBuckets
+		histograms[i].Buckets = make([]uint64, len(metrics.latencyBuckets)+1)
+	}
+	return histograms
+}
@@ -0,0 +1,288 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Multi-channel DMA transfers. DMA engines such as Xilinx XDMA provide several
+// host-to-card (h2c) and card-to-host (c2h) channels, each with its own
+// character device. Large transfers are split into stripes, which are
+// distributed round-robin across the channels and transferred in parallel.
+// Transfers no larger than a single stripe and devices with a single channel
+// are transferred directly on the first channel.
+//
+
+package gopcie
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// pcieDMADefaultStripeSize is the default size of the stripes transfers are
+// split into.
+const pcieDMADefaultStripeSize = 1 << 20
+
+// PCIeDMAChannelStats holds the statistics of a DMA channel.
+type PCIeDMAChannelStats struct {
+	Device    string        // device path of the channel
+	Op        string        // "read" or "write"
+	Transfers uint64        // number of stripes transferred
+	Bytes     uint64        // number of bytes transferred
+	Errors    uint64        // number of failed stripe transfers
+	Busy      time.Duration // total time spent transferring
+}
+
+// pcieDMAChannel is a single channel of a multi-channel DMA device.
+type pcieDMAChannel struct {
+	dev *PCIeDMA
+
+	// the mutex protects the statistics
+	mutex sync.Mutex
+	stats PCIeDMAChannelStats
+}
+
+// PCIeDMAMultiChannel implements DMA transfers striped across multiple
+// channels.
+type PCIeDMAMultiChannel struct {
+	writeChannels []*pcieDMAChannel
+	readChannels  []*pcieDMAChannel
+	stripeSize    int
+}
+
+// PCIeDMAMultiChannelOpen opens the host-to-card (write) and card-to-host
+// (read) channel devices with the specified names. One of the lists may be
+// empty, in which case the respective transfer direction is not available.
+// Transfers are split into stripes of the specified size. A stripe size of
+// zero selects a default size of 1 MByte.
+func PCIeDMAMultiChannelOpen(writeDevNames, readDevNames []string,
+	stripeSize int) (*PCIeDMAMultiChannel, error) {
+	if (len(writeDevNames) == 0 && len(readDevNames) == 0) || stripeSize < 0 {
+		return nil, newPCIeError("open multi-channel dma", "",
+			ErrInvalidArgument)
+	}
+	if stripeSize == 0 {
+		stripeSize = pcieDMADefaultStripeSize
+	}
+
+	mc := &PCIeDMAMultiChannel{stripeSize: stripeSize}
+
+	// open channels
+	open := func(devNames []string, accessMode int,
+		op string) ([]*pcieDMAChannel, error) {
+		var channels []*pcieDMAChannel
+		for _, devName := range devNames {
+			dev, err := PCIeDMAOpen(devName, accessMode)
+			if err != nil {
+				return channels, err
+			}
+			channels = append(channels, &pcieDMAChannel{
+				dev:   dev,
+				stats: PCIeDMAChannelStats{Device: devName, Op: op},
+			})
+		}
+		return channels, nil
+	}
+
+	var err error
+	mc.writeChannels, err = open(writeDevNames, PCIE_ACCESS_WRITE, "write")
+	if err == nil {
+		mc.readChannels, err = open(readDevNames, PCIE_ACCESS_READ, "read")
+	}
+	if err != nil {
+		mc.Close()
+		return nil, err
+	}
+
+	return mc, nil
+}
+
+// Close closes all channels. Closing an already closed device has no effect.
+func (mc *PCIeDMAMultiChannel) Close() error {
+	var err error
+	for _, channels := range [][]*pcieDMAChannel{mc.writeChannels,
+		mc.readChannels} {
+		for _, channel := range channels {
+			if errClose := channel.dev.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}
+	}
+	return err
+}
+
+// WriteChannels returns the host-to-card channel devices, e.g. to configure
+// their maximum transfer size.
+func (mc *PCIeDMAMultiChannel) WriteChannels() []*PCIeDMA {
+	return channelDevices(mc.writeChannels)
+}
+
+// ReadChannels returns the card-to-host channel devices, e.g. to configure
+// their maximum transfer size.
+func (mc *PCIeDMAMultiChannel) ReadChannels() []*PCIeDMA {
+	return channelDevices(mc.readChannels)
+}
+
+// channelDevices returns the devices of the channels.
+func channelDevices(channels []*pcieDMAChannel) []*PCIeDMA {
+	devs := make([]*PCIeDMA, len(channels))
+	for i, channel := range channels {
+		devs[i] = channel.dev
+	}
+	return devs
+}
+
+// Write performs a DMA write transfer striped across the host-to-card
+// channels. If the transfer of a stripe fails, a *PCIeTransferError of the
+// whole transfer is returned (see transfer).
+func (mc *PCIeDMAMultiChannel) Write(addr uint64, data []byte) error {
+	if len(mc.writeChannels) == 0 {
+		return ErrNotWritable
+	}
+	return mc.transfer(mc.writeChannels, addr, data)
+}
+
+// Read performs a DMA read transfer striped across the card-to-host channels.
+// If the transfer of a stripe fails, a *PCIeTransferError of the whole
+// transfer is returned (see transfer).
+func (mc *PCIeDMAMultiChannel) Read(addr uint64, data []byte) error {
+	if len(mc.readChannels) == 0 {
+		return ErrNotReadable
+	}
+	return mc.transfer(mc.readChannels, addr, data)
+}
+
+// transfer splits the transfer into stripes and transfers them in parallel.
+// Channel i transfers stripes i, i+n, i+2n, ... of n channels. A channel stops
+// at its first failed stripe. If a stripe fails, the returned error reports the
+// number of bytes transferred by all stripes, the device path of the failed
+// stripe with the lowest card address and wraps the underlying error of that
+// stripe. Since stripes are transferred in parallel, the transferred bytes are
+// not necessarily contiguous.
+func (mc *PCIeDMAMultiChannel) transfer(channels []*pcieDMAChannel,
+	addr uint64, data []byte) error {
+	// fall back to a single channel for small transfers
+	nStripes := (len(data) + mc.stripeSize - 1) / mc.stripeSize
+	if nStripes <= 1 || len(channels) == 1 {
+		return channels[0].transfer(addr, data)
+	}
+
+	nChannels := len(channels)
+	if nChannels > nStripes {
+		nChannels = nStripes
+	}
+
+	errs := make([]error, nStripes)
+	transferred := make([]int, nStripes)
+	var wg sync.WaitGroup
+	for i := 0; i < nChannels; i++ {
+		wg.Add(1)
+		go func(channel *pcieDMAChannel, firstStripe int) {
+			defer wg.Done()
+			for stripe := firstStripe; stripe < nStripes; stripe += nChannels {
+				start := stripe * mc.stripeSize
+				end := start + mc.stripeSize
+				if end > len(data) {
+					end = len(data)
+				}
+				errs[stripe] = channel.transfer(addr+uint64(start),
+					data[start:end])
+				if errs[stripe] != nil {
+					var transferErr *PCIeTransferError
+					if errors.As(errs[stripe], &transferErr) {
+						transferred[stripe] = transferErr.Transferred
+					}
+					return
+				}
+				transferred[stripe] = end - start
+			}
+		}(channels[i], i)
+	}
+	wg.Wait()
+
+	var err error
+	var errPath string
+	total := 0
+	for stripe := range errs {
+		total += transferred[stripe]
+		if errs[stripe] != nil && err == nil {
+			err = errs[stripe]
+			errPath = channels[stripe%nChannels].dev.devName
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	var transferErr *PCIeTransferError
+	if errors.As(err, &transferErr) {
+		err = transferErr.Err
+	}
+	return newPCIeTransferError(channels[0].stats.Op, errPath, addr,
+		len(data), total, err)
+}
+
+// transfer transfers a stripe on the channel and updates its statistics.
+func (channel *pcieDMAChannel) transfer(addr uint64, data []byte) error {
+	startTime := time.Now()
+	var err error
+	if channel.stats.Op == "write" {
+		err = channel.dev.Write(addr, data)
+	} else {
+		err = channel.dev.Read(addr, data)
+	}
+	duration := time.Since(startTime)
+
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+	channel.stats.Transfers++
+	channel.stats.Busy += duration
+	if err != nil {
+		channel.stats.Errors++
+		if transferErr, ok := err.(*PCIeTransferError); ok {
+			channel.stats.Bytes += uint64(transferErr.Transferred)
+		}
+	} else {
+		channel.stats.Bytes += uint64(len(data))
+	}
+	return err
+}
+
+// Stats returns the statistics of all channels, host-to-card channels first.
+func (mc *PCIeDMAMultiChannel) Stats() []PCIeDMAChannelStats {
+	var stats []PCIeDMAChannelStats
+	for _, channels := range [][]*pcieDMAChannel{mc.writeChannels,
+		mc.readChannels} {
+		for _, channel := range channels {
+			channel.mutex.Lock()
+			stats = append(stats, channel.stats)
+			channel.mutex.Unlock()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,240 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// NUMA-aware placement. On multi-socket systems, DMA buffers should be
+// allocated on the NUMA node the PCIExpress device is attached to and the
+// goroutines performing the transfers should run on the CPUs of that node.
+//
+
+package gopcie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// mbind(2) constants
+const (
+	mpolBind     = 2
+	mpolMfMove   = 1 << 1
+	numaMaskBits = 1024
+)
+
+// PCIeNumaNode returns the NUMA node the device with the specified PCI address
+// is attached to. It returns -1 if the system does not report a NUMA node for
+// the device (e.g. on single-socket systems).
+func PCIeNumaNode(devAddr string) (int, error) {
+	nodeFilename := filepath.Join(pcieSysfsDevicesDir, devAddr, "numa_node")
+	nodeFile, err := ioutil.ReadFile(nodeFilename)
+	if err != nil {
+		return -1, newPCIeError("read pci numa_node file", nodeFilename, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(nodeFile)))
+	if err != nil {
+		return -1, newPCIeError("parse pci numa_node file", nodeFilename, err)
+	}
+	return node, nil
+}
+
+// PCIeLocalCPUs returns the CPUs local to the device with the specified PCI
+// address.
+func PCIeLocalCPUs(devAddr string) ([]int, error) {
+	cpuListFilename := filepath.Join(pcieSysfsDevicesDir, devAddr,
+		"local_cpulist")
+	cpuListFile, err := ioutil.ReadFile(cpuListFilename)
+	if err != nil {
+		return nil, newPCIeError("read pci local_cpulist file",
+			cpuListFilename, err)
+	}
+	cpus, err := parseCPUList(strings.TrimSpace(string(cpuListFile)))
+	if err != nil {
+		return nil, newPCIeError("parse pci local_cpulist file",
+			cpuListFilename, err)
+	}
+	return cpus, nil
+}
+
+// PinThreadToCPUs locks the calling goroutine to its current operating system
+// thread and restricts the thread to run on the specified CPUs. The goroutine
+// should call runtime.UnlockOSThread once it no longer needs to be pinned.
+func PinThreadToCPUs(cpus []int) error {
+	if len(cpus) == 0 {
+		return newPCIeError("set cpu affinity", "", ErrInvalidArgument)
+	}
+
+	// create cpu mask
+	var mask [numaMaskBits / 64]uint64
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= numaMaskBits {
+			return newPCIeError(fmt.Sprintf("set cpu affinity to cpu %d", cpu),
+				"", ErrInvalidArgument)
+		}
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+
+	// the affinity applies to the calling thread, so the goroutine must not be
+	// moved to another thread afterwards
+	runtime.LockOSThread()
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0,
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		runtime.UnlockOSThread()
+		return newPCIeError("set cpu affinity", "", errno)
+	}
+	return nil
+}
+
+// DMABufferAllocNode allocates a host memory buffer like DMABufferAlloc, but
+// places its memory on the specified NUMA node. If the node is negative, the
+// memory is placed by the operating system.
+func DMABufferAllocNode(size uint64, flags int,
+	numaNode int) (*PCIeDMABuffer, error) {
+	return dmaBufferAlloc(size, flags, numaNode)
+}
+
+// NumaNode returns the NUMA node the DMA device is attached to. It returns -1
+// if the system does not report a NUMA node for the device.
+func (dev *PCIeDMA) NumaNode() (int, error) {
+	devAddr, err := pcieDeviceAddrOfDev(dev.devName)
+	if err != nil {
+		return -1, err
+	}
+	return PCIeNumaNode(devAddr)
+}
+
+// PinThreadToLocalCPUs locks the calling goroutine to its current operating
+// system thread and restricts the thread to run on the CPUs local to the DMA
+// device.
+func (dev *PCIeDMA) PinThreadToLocalCPUs() error {
+	devAddr, err := pcieDeviceAddrOfDev(dev.devName)
+	if err != nil {
+		return err
+	}
+	cpus, err := PCIeLocalCPUs(devAddr)
+	if err != nil {
+		return err
+	}
+	return PinThreadToCPUs(cpus)
+}
+
+// BufferAllocLocal allocates a host memory buffer like DMABufferAlloc on the
+// NUMA node the DMA device is attached to.
+func (dev *PCIeDMA) BufferAllocLocal(size uint64,
+	flags int) (*PCIeDMABuffer, error) {
+	numaNode, err := dev.NumaNode()
+	if err != nil {
+		return nil, err
+	}
+	return dmaBufferAlloc(size, flags, numaNode)
+}
+
+// PinToDeviceNode prepares the calling goroutine for transfers of the DMA
+// device: it pins the goroutine to the CPUs local to the device (see
+// PinThreadToLocalCPUs) and allocates a buffer of the specified size on the
+// device's NUMA node (see BufferAllocLocal). The returned function releases the
+// buffer and unlocks the goroutine from its thread; it must be called once the
+// buffer is no longer used. If pinning or the local allocation fails, the
+// buffer is allocated by the go runtime instead and the error is returned
+// along with it, so that callers may warn and continue.
+func PinToDeviceNode(dev *PCIeDMA, size uint64) ([]byte, func(), error) {
+	// run on the cpus local to the device
+	errPin := dev.PinThreadToLocalCPUs()
+	release := func() {}
+	if errPin == nil {
+		release = runtime.UnlockOSThread
+	}
+
+	// allocate the buffer on the device's numa node
+	buf, err := dev.BufferAllocLocal(size, 0)
+	if err != nil {
+		if errPin == nil {
+			errPin = err
+		}
+		return make([]byte, size), release, errPin
+	}
+	return buf.Bytes()[:size], func() {
+		buf.Close()
+		release()
+	}, errPin
+}
+
+// numaBind binds the memory to the specified NUMA node. Pages that have already
+// been faulted in are moved to the node.
+func numaBind(data []byte, numaNode int) error {
+	if numaNode >= numaMaskBits {
+		return newPCIeError(fmt.Sprintf("bind memory to numa node %d",
+			numaNode), "", ErrInvalidArgument)
+	}
+
+	var nodeMask [numaMaskBits / 64]uint64
+	nodeMask[numaNode/64] = 1 << uint(numaNode%64)
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_MBIND,
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), mpolBind,
+		uintptr(unsafe.Pointer(&nodeMask[0])), numaMaskBits, mpolMfMove)
+	if errno != 0 {
+		return newPCIeError(fmt.Sprintf("bind memory to numa node %d",
+			numaNode), "", errno)
+	}
+	return nil
+}
+
+// parseCPUList parses a cpu list as used by sysfs (e.g. "0-7,16-23").
+func parseCPUList(cpuList string) ([]int, error) {
+	var cpus []int
+	if cpuList == "" {
+		return cpus, nil
+	}
+	for _, cpuRange := range strings.Split(cpuList, ",") {
+		bounds := strings.SplitN(cpuRange, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
@@ -0,0 +1,249 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Pool of reusable DMA buffers. Buffers are page- or hugepage-aligned (some
+// drivers bounce-buffer unaligned user memory) and optionally locked in memory.
+// Buffer sizes are rounded up to size classes (power of two multiples of the
+// page size), released buffers are kept for reuse, so that transfers on hot
+// paths do not allocate.
+//
+
+package gopcie
+
+import (
+	"os"
+	"sync"
+)
+
+// pcieDMABufferPoolMaxFree is the number of released buffers per size class
+// kept by the pool of a DMA device.
+const pcieDMABufferPoolMaxFree = 16
+
+// pcieDMABufferPoolMaxClass is the largest size class (unless the page size is
+// larger). Larger buffers are allocated with their exact (page-aligned) size
+// and are not kept by the pool, since rounding them up to a power of two would
+// waste up to half of the (pre-faulted) memory.
+const pcieDMABufferPoolMaxClass = 4 << 20
+
+// PCIeDMABufferPool hands out reusable DMA buffers.
+type PCIeDMABufferPool struct {
+	mutex    sync.Mutex
+	flags    int
+	numaNode int
+	pageSize uint64
+	maxClass uint64
+	maxFree  int
+
+	// released buffers per size class
+	free   map[uint64][]*PCIeDMABuffer
+	closed bool
+}
+
+// NewPCIeDMABufferPool creates a buffer pool. The flags are passed to
+// DMABufferAlloc when allocating buffers. Up to maxFree released buffers are
+// kept per size class, additional ones are freed. A maxFree of zero keeps all
+// released buffers.
+func NewPCIeDMABufferPool(flags, maxFree int) (*PCIeDMABufferPool, error) {
+	return newPCIeDMABufferPool(flags, maxFree, -1)
+}
+
+// newPCIeDMABufferPool creates a buffer pool allocating buffers on the
+// specified NUMA node. If the node is negative, the memory is placed by the
+// operating system.
+func newPCIeDMABufferPool(flags, maxFree,
+	numaNode int) (*PCIeDMABufferPool, error) {
+	if maxFree < 0 {
+		return nil, newPCIeError("create dma buffer pool", "",
+			ErrInvalidArgument)
+	}
+
+	// determine page size
+	pageSize := uint64(os.Getpagesize())
+	if (flags & DMA_BUFFER_HUGEPAGE) != 0 {
+		var err error
+		pageSize, err = hugepageSize()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the largest size class holds at least a single page
+	maxClass := uint64(pcieDMABufferPoolMaxClass)
+	if maxClass < pageSize {
+		maxClass = pageSize
+	}
+
+	return &PCIeDMABufferPool{
+		flags:    flags,
+		numaNode: numaNode,
+		pageSize: pageSize,
+		maxClass: maxClass,
+		maxFree:  maxFree,
+		free:     make(map[uint64][]*PCIeDMABuffer),
+	}, nil
+}
+
+// Get returns a buffer of at least the specified size. The size of the
+// returned buffer is the size rounded up to its size class, i.e. callers
+// typically use buf.Bytes()[:size]. The buffer should be returned to the pool
+// via Put when it is no longer needed. Buffers larger than the largest size
+// class (4 MiB or a single hugepage) are only rounded up to a multiple of the
+// page size. They are not kept by the pool and are freed by Put.
+func (pool *PCIeDMABufferPool) Get(size uint64) (*PCIeDMABuffer, error) {
+	if size == 0 {
+		return nil, newPCIeError("get pooled dma buffer", "",
+			ErrInvalidArgument)
+	}
+
+	// determine size class
+	if size > pool.maxClass {
+		return dmaBufferAlloc(size, pool.flags, pool.numaNode)
+	}
+	class := pool.pageSize
+	for class < size {
+		class <<= 1
+	}
+
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return nil, newPCIeError("get pooled dma buffer", "", ErrClosed)
+	}
+
+	// reuse released buffer
+	if free := pool.free[class]; len(free) > 0 {
+		buf := free[len(free)-1]
+		free[len(free)-1] = nil
+		pool.free[class] = free[:len(free)-1]
+		pool.mutex.Unlock()
+		return buf, nil
+	}
+	pool.mutex.Unlock()
+
+	// allocate new buffer
+	return dmaBufferAlloc(class, pool.flags, pool.numaNode)
+}
+
+// Put returns a buffer to the pool. The buffer must not be used afterwards.
+func (pool *PCIeDMABufferPool) Put(buf *PCIeDMABuffer) {
+	if buf == nil || buf.data == nil {
+		return
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	// free buffer if the pool is closed, the buffer is larger than the largest
+	// size class or enough buffers of the size class are kept already
+	class := buf.Size()
+	if pool.closed || class > pool.maxClass ||
+		(pool.maxFree > 0 && len(pool.free[class]) >= pool.maxFree) {
+		buf.Close()
+		return
+	}
+	pool.free[class] = append(pool.free[class], buf)
+}
+
+// Close frees all released buffers. Buffers returned to the pool afterwards
+// are freed right away. Closing an already closed pool has no effect.
+func (pool *PCIeDMABufferPool) Close() error {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	var err error
+	for class, free := range pool.free {
+		for _, buf := range free {
+			if errClose := buf.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}
+		delete(pool.free, class)
+	}
+	pool.closed = true
+	return err
+}
+
+// BufferPoolLocal creates a buffer pool like NewPCIeDMABufferPool whose
+// buffers are allocated on the NUMA node the DMA device is attached to.
+func (dev *PCIeDMA) BufferPoolLocal(flags,
+	maxFree int) (*PCIeDMABufferPool, error) {
+	numaNode, err := dev.NumaNode()
+	if err != nil {
+		return nil, err
+	}
+	return newPCIeDMABufferPool(flags, maxFree, numaNode)
+}
+
+// BufferPool returns the buffer pool of the DMA device. Unless a pool has been
+// set via SetBufferPool, a pool of regular, unlocked buffers on the NUMA node
+// the device is attached to is created on first use. It is closed when the
+// device is closed.
+func (dev *PCIeDMA) BufferPool() (*PCIeDMABufferPool, error) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if dev.bufferPool != nil {
+		return dev.bufferPool, nil
+	}
+	if dev.fd == nil {
+		return nil, newPCIeError("create dma buffer pool", dev.devName,
+			ErrClosed)
+	}
+
+	// the numa node is unknown for devices not backed by a pci device. in
+	// that case memory is placed by the operating system
+	numaNode := -1
+	if devAddr, err := pcieDeviceAddrOfDev(dev.devName); err == nil {
+		numaNode, _ = PCIeNumaNode(devAddr)
+	}
+
+	pool, err := newPCIeDMABufferPool(0, pcieDMABufferPoolMaxFree, numaNode)
+	if err != nil {
+		return nil, err
+	}
+	dev.bufferPool = pool
+	dev.bufferPoolOwned = true
+	return pool, nil
+}
+
+// SetBufferPool sets the buffer pool of the DMA device, e.g. to use a pool of
+// hugepage-backed or locked buffers. The pool is not closed when the device is
+// closed.
+func (dev *PCIeDMA) SetBufferPool(pool *PCIeDMABufferPool) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+
+	if dev.bufferPoolOwned {
+		dev.bufferPool.Close()
+	}
+	dev.bufferPool = pool
+	dev.bufferPoolOwned = false
+}
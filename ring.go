@@ -0,0 +1,276 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Ring buffer in card memory. The ring buffer is accessed via DMA, its head
+// (producer) and tail (consumer) pointers are BAR registers holding byte
+// offsets into the ring buffer. The ring buffer is empty if head and tail are
+// equal. One byte is always kept free, so that a full ring buffer can be told
+// apart from an empty one. Depending on the design, the host either produces
+// data consumed by the card (Write) or consumes data produced by the card
+// (Read). While waiting for the card, the pointer registers are polled or a
+// user interrupt is waited for.
+//
+
+package gopcie
+
+import (
+	"context"
+	"time"
+)
+
+// pcieRingPollInterval is the default interval in which the pointer registers
+// are polled while waiting for the card.
+const pcieRingPollInterval = 100 * time.Microsecond
+
+// PCIeRingConfig holds the configuration of a ring buffer in card memory.
+type PCIeRingConfig struct {
+	Addr    uint64 // card address of the ring buffer
+	Size    uint64 // size of the ring buffer in bytes
+	HeadReg uint32 // BAR address of the head (producer) pointer register
+	TailReg uint32 // BAR address of the tail (consumer) pointer register
+
+	// Event, if set, is the user interrupt the card signals after advancing
+	// a pointer. Otherwise, the pointer registers are polled.
+	Event *PCIeEvent
+
+	// PollInterval is the interval in which the pointer registers are polled
+	// (and re-checked while waiting for an interrupt). Defaults to 100us.
+	PollInterval time.Duration
+}
+
+// PCIeRing implements a ring buffer in card memory.
+type PCIeRing struct {
+	dma *PCIeDMA
+	bar *PCIeBAR
+	cfg PCIeRingConfig
+}
+
+// NewPCIeRing creates a ring buffer in card memory accessed via the DMA device
+// with its pointer registers located in the BAR. The pointer registers must be
+// 32 bit aligned and located within the (open) BAR.
+func NewPCIeRing(dma *PCIeDMA, bar *PCIeBAR,
+	cfg PCIeRingConfig) (*PCIeRing, error) {
+	if cfg.Size < 2 || cfg.Size > 1<<32 || cfg.PollInterval < 0 ||
+		cfg.HeadReg%4 != 0 || cfg.TailReg%4 != 0 ||
+		uint64(cfg.HeadReg)+4 > uint64(bar.Size()) ||
+		uint64(cfg.TailReg)+4 > uint64(bar.Size()) {
+		return nil, newPCIeError("create ring buffer", dma.devName,
+			ErrInvalidArgument)
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = pcieRingPollInterval
+	}
+	return &PCIeRing{dma: dma, bar: bar, cfg: cfg}, nil
+}
+
+// Size returns the size of the ring buffer in bytes.
+func (ring *PCIeRing) Size() uint64 {
+	return ring.cfg.Size
+}
+
+// pointers reads the head and tail pointer registers.
+func (ring *PCIeRing) pointers() (uint64, uint64, error) {
+	head, err := ring.bar.TryRead(ring.cfg.HeadReg)
+	if err != nil {
+		return 0, 0, err
+	}
+	tail, err := ring.bar.TryRead(ring.cfg.TailReg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if uint64(head) >= ring.cfg.Size || uint64(tail) >= ring.cfg.Size {
+		return 0, 0, newPCIeError("read ring buffer pointers",
+			ring.dma.devName, ErrOutOfRange)
+	}
+	return uint64(head), uint64(tail), nil
+}
+
+// used returns the number of bytes in the ring buffer.
+func (ring *PCIeRing) used(head, tail uint64) uint64 {
+	return (head + ring.cfg.Size - tail) % ring.cfg.Size
+}
+
+// Used returns the number of bytes in the ring buffer.
+func (ring *PCIeRing) Used() (uint64, error) {
+	head, tail, err := ring.pointers()
+	if err != nil {
+		return 0, err
+	}
+	return ring.used(head, tail), nil
+}
+
+// Free returns the number of bytes that can be written to the ring buffer.
+func (ring *PCIeRing) Free() (uint64, error) {
+	used, err := ring.Used()
+	if err != nil {
+		return 0, err
+	}
+	return ring.cfg.Size - 1 - used, nil
+}
+
+// Empty returns true if the ring buffer is empty.
+func (ring *PCIeRing) Empty() (bool, error) {
+	used, err := ring.Used()
+	return used == 0, err
+}
+
+// Full returns true if no more data can be written to the ring buffer.
+func (ring *PCIeRing) Full() (bool, error) {
+	free, err := ring.Free()
+	return free == 0, err
+}
+
+// Write writes data to the ring buffer as producer and advances the head
+// pointer. If the ring buffer is full, Write blocks until the card consumed
+// data. It returns when all data has been written or the context is done. It
+// returns the number of bytes written.
+func (ring *PCIeRing) Write(ctx context.Context, data []byte) (int, error) {
+	nBytesWritten := 0
+	for nBytesWritten < len(data) {
+		head, tail, err := ring.pointers()
+		if err != nil {
+			return nBytesWritten, err
+		}
+
+		// wait for the card to consume data if the ring buffer is full
+		free := ring.cfg.Size - 1 - ring.used(head, tail)
+		if free == 0 {
+			if err := ring.wait(ctx); err != nil {
+				return nBytesWritten, err
+			}
+			continue
+		}
+
+		// write as much data as fits
+		n := uint64(len(data) - nBytesWritten)
+		if n > free {
+			n = free
+		}
+		segs := ring.segments(head, data[nBytesWritten:nBytesWritten+int(n)])
+		if err := ring.dma.WriteSegments(segs); err != nil {
+			return nBytesWritten, err
+		}
+
+		// advance head pointer
+		if err := ring.bar.TryWrite(ring.cfg.HeadReg,
+			uint32((head+n)%ring.cfg.Size)); err != nil {
+			return nBytesWritten, err
+		}
+		nBytesWritten += int(n)
+	}
+	return nBytesWritten, nil
+}
+
+// Read reads data from the ring buffer as consumer and advances the tail
+// pointer. If the ring buffer is empty, Read blocks until the card produced
+// data or the context is done. It returns the number of bytes read, which may
+// be less than len(data) if the ring buffer holds less data.
+func (ring *PCIeRing) Read(ctx context.Context, data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	for {
+		head, tail, err := ring.pointers()
+		if err != nil {
+			return 0, err
+		}
+
+		// wait for the card to produce data if the ring buffer is empty
+		used := ring.used(head, tail)
+		if used == 0 {
+			if err := ring.wait(ctx); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// read as much data as available
+		n := uint64(len(data))
+		if n > used {
+			n = used
+		}
+		if err := ring.dma.ReadSegments(ring.segments(tail,
+			data[:n])); err != nil {
+			return 0, err
+		}
+
+		// advance tail pointer
+		if err := ring.bar.TryWrite(ring.cfg.TailReg,
+			uint32((tail+n)%ring.cfg.Size)); err != nil {
+			return 0, err
+		}
+		return int(n), nil
+	}
+}
+
+// segments splits a transfer starting at the specified ring buffer offset into
+// two segments if it wraps around the end of the ring buffer.
+func (ring *PCIeRing) segments(offset uint64, data []byte) []PCIeDMASegment {
+	n := uint64(len(data))
+	if offset+n <= ring.cfg.Size {
+		return []PCIeDMASegment{{Addr: ring.cfg.Addr + offset, Data: data}}
+	}
+	split := ring.cfg.Size - offset
+	return []PCIeDMASegment{
+		{Addr: ring.cfg.Addr + offset, Data: data[:split]},
+		{Addr: ring.cfg.Addr, Data: data[split:]},
+	}
+}
+
+// wait waits for the card to advance a pointer. Without interrupt, it sleeps
+// for the poll interval. With interrupt, it waits for the interrupt for at most
+// the poll interval, so that pointer updates are not missed if the interrupt
+// fired before waiting started.
+func (ring *PCIeRing) wait(ctx context.Context) error {
+	if ring.cfg.Event == nil {
+		timer := time.NewTimer(ring.cfg.PollInterval)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, ring.cfg.PollInterval)
+	defer cancel()
+	_, err := ring.cfg.Event.Wait(waitCtx)
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		// poll interval expired
+		return nil
+	}
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
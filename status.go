@@ -0,0 +1,199 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Status of PCIExpress devices as reported by sysfs: presence, negotiated and
+// maximum link speed and width, and Advanced Error Reporting (AER) counters.
+//
+
+package gopcie
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PCIeLinkStatus holds the negotiated and maximum link speed and width of a
+// device. Speeds are in GT/s and are zero if unknown.
+type PCIeLinkStatus struct {
+	Speed    float64 // negotiated link speed
+	Width    int     // negotiated link width (number of lanes)
+	MaxSpeed float64 // maximum link speed
+	MaxWidth int     // maximum link width (number of lanes)
+}
+
+// PCIeAERCounters holds the Advanced Error Reporting counters of a device,
+// keyed by error name (e.g. "RxErr" or "BadTLP").
+type PCIeAERCounters struct {
+	Correctable map[string]uint64
+	NonFatal    map[string]uint64
+	Fatal       map[string]uint64
+}
+
+// PCIeDevicePresent returns true if the device with the specified PCI address
+// (e.g. "0000:01:00.0") is present.
+func PCIeDevicePresent(devAddr string) bool {
+	_, err := os.Stat(filepath.Join(pcieSysfsDevicesDir, devAddr))
+	return err == nil
+}
+
+// PCIeReadLinkStatus reads the link status of the device with the specified
+// PCI address.
+func PCIeReadLinkStatus(devAddr string) (PCIeLinkStatus, error) {
+	var status PCIeLinkStatus
+	var err error
+	if status.Speed, err = pcieSysfsReadLinkSpeed(devAddr,
+		"current_link_speed"); err != nil {
+		return PCIeLinkStatus{}, err
+	}
+	if status.Width, err = pcieSysfsReadInt(devAddr,
+		"current_link_width"); err != nil {
+		return PCIeLinkStatus{}, err
+	}
+	if status.MaxSpeed, err = pcieSysfsReadLinkSpeed(devAddr,
+		"max_link_speed"); err != nil {
+		return PCIeLinkStatus{}, err
+	}
+	if status.MaxWidth, err = pcieSysfsReadInt(devAddr,
+		"max_link_width"); err != nil {
+		return PCIeLinkStatus{}, err
+	}
+	return status, nil
+}
+
+// PCIeReadAERCounters reads the AER counters of the device with the specified
+// PCI address. If the kernel does not report AER counters for the device (e.g.
+// because the device does not support AER), an error wrapping ErrUnsupported is
+// returned.
+func PCIeReadAERCounters(devAddr string) (PCIeAERCounters, error) {
+	var counters PCIeAERCounters
+	files := []struct {
+		attr     string
+		counters *map[string]uint64
+	}{
+		{"aer_dev_correctable", &counters.Correctable},
+		{"aer_dev_nonfatal", &counters.NonFatal},
+		{"aer_dev_fatal", &counters.Fatal},
+	}
+	for _, file := range files {
+		var err error
+		*file.counters, err = pcieSysfsReadAER(devAddr, file.attr)
+		if err != nil {
+			return PCIeAERCounters{}, err
+		}
+	}
+	return counters, nil
+}
+
+// pcieSysfsReadAttr reads a sysfs attribute file of the device with the
+// specified PCI address and returns its content without trailing whitespace.
+func pcieSysfsReadAttr(devAddr, attr string) (string, error) {
+	filename := filepath.Join(pcieSysfsDevicesDir, devAddr, attr)
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", newPCIeError("read pci "+attr+" file", filename, err)
+	}
+	return string(bytes.TrimSpace(content)), nil
+}
+
+// pcieSysfsReadInt reads a decimal integer attribute file of the device with
+// the specified PCI address.
+func pcieSysfsReadInt(devAddr, attr string) (int, error) {
+	content, err := pcieSysfsReadAttr(devAddr, attr)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(content)
+	if err != nil {
+		return 0, newPCIeError("parse pci "+attr+" file",
+			filepath.Join(pcieSysfsDevicesDir, devAddr, attr), err)
+	}
+	return value, nil
+}
+
+// pcieSysfsReadLinkSpeed reads a link speed attribute file (e.g. "8.0 GT/s
+// PCIe") of the device with the specified PCI address. It returns the speed in
+// GT/s, or zero if the speed is unknown.
+func pcieSysfsReadLinkSpeed(devAddr, attr string) (float64, error) {
+	content, err := pcieSysfsReadAttr(devAddr, attr)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(content)
+	if len(fields) < 2 || fields[1] != "GT/s" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, newPCIeError("parse pci "+attr+" file",
+			filepath.Join(pcieSysfsDevicesDir, devAddr, attr), err)
+	}
+	return speed, nil
+}
+
+// pcieSysfsReadAER reads an AER counter attribute file of the device with the
+// specified PCI address. Each line of the file holds an error name and its
+// counter. The total counter line is skipped.
+func pcieSysfsReadAER(devAddr, attr string) (map[string]uint64, error) {
+	filename := filepath.Join(pcieSysfsDevicesDir, devAddr, attr)
+	content, err := pcieSysfsReadAttr(devAddr, attr)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, newPCIeError("read pci "+attr+" file", filename,
+			ErrUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]uint64)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, newPCIeError("parse pci "+attr+" file", filename,
+				ErrUnsupported)
+		}
+		if strings.HasPrefix(fields[0], "TOTAL_") {
+			continue
+		}
+		counter, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, newPCIeError("parse pci "+attr+" file", filename, err)
+		}
+		counters[fields[0]] = counter
+	}
+	return counters, nil
+}
@@ -0,0 +1,352 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Streaming (AXI-Stream) DMA transfers. On DMA channels configured for
+// streaming, card addresses are meaningless and transfers are packet-oriented:
+// every write system call sends a single packet (end-of-packet is signaled
+// with its last byte) and a read system call returns at most one packet. A
+// read that returns fewer bytes than requested has reached the end of the
+// packet, otherwise the remainder of the packet is returned by the following
+// reads. A read returning zero bytes therefore only signals the end of a packet
+// that ended exactly at the end of the previous read, not the end of the
+// stream. Repeated empty reads mean that no data is available; further reads
+// are delayed until the driver signals data via poll(2), or by a poll interval
+// if the driver does not support it. The stream ends when the device is
+// closed.
+//
+
+package gopcie
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"time"
+)
+
+// pcieStreamPollInterval is the maximum time waited for data after repeated
+// empty reads.
+const pcieStreamPollInterval = time.Millisecond
+
+// PCIeDMAStream implements streaming DMA transfers on a DMA channel configured
+// for streaming. It implements io.Reader and io.Writer.
+type PCIeDMAStream struct {
+	dev *PCIeDMA
+}
+
+// PCIeDMAPacket is a packet received by PCIeDMAStream.Receive.
+type PCIeDMAPacket struct {
+	Data      []byte // packet data
+	Truncated bool   // true if the packet exceeded the maximum packet size
+	Err       error  // error that ended the receive loop (Data is empty)
+}
+
+// Stream returns the streaming interface of a DMA channel configured for
+// streaming.
+func (dev *PCIeDMA) Stream() *PCIeDMAStream {
+	return &PCIeDMAStream{dev: dev}
+}
+
+// Write sends the data as a single packet. It implements io.Writer.
+func (stream *PCIeDMAStream) Write(p []byte) (int, error) {
+	// check if access mode allows writing
+	if (stream.dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
+		return 0, ErrNotWritable
+	}
+
+	n, err := stream.transfer("write", p)
+	if err == nil && n < len(p) {
+		err = newPCIeTransferError("write", stream.dev.devName, 0, len(p), n,
+			nil)
+	}
+	return n, err
+}
+
+// Read reads data from the stream into p. It implements io.Reader and ignores
+// packet boundaries, i.e. a read returns at most the remainder of the current
+// packet. Empty reads marking the end of a packet are skipped. Read blocks
+// until data is available, waiting for it without spinning if the driver
+// repeatedly returns empty reads. io.EOF is returned once the device has been
+// closed; other errors are returned as reported by the driver.
+func (stream *PCIeDMAStream) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var backoff streamBackoff
+	for {
+		n, _, err := stream.ReadPacket(p)
+		if err == nil && n == 0 {
+			err = backoff.wait(stream)
+		}
+		if errors.Is(err, ErrClosed) {
+			return 0, io.EOF
+		}
+		if err != nil || n > 0 {
+			return n, err
+		}
+	}
+}
+
+// streamBackoff delays retries after repeated empty reads.
+type streamBackoff struct {
+	empty int
+	sleep bool
+}
+
+// wait is called after an empty read. The first empty read marks the end of a
+// packet and is retried right away. After further empty reads, it waits until
+// the driver signals data via poll(2), for at most pcieStreamPollInterval. If
+// the driver signaled data (or does not support poll) but the following read
+// is empty again, it sleeps for the poll interval instead.
+func (backoff *streamBackoff) wait(stream *PCIeDMAStream) error {
+	backoff.empty++
+	if backoff.empty == 1 {
+		return nil
+	}
+	if backoff.sleep {
+		time.Sleep(pcieStreamPollInterval)
+		return nil
+	}
+
+	dev := stream.dev
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+	if dev.fd == nil {
+		return newPCIeError("wait for stream data", dev.devName, ErrClosed)
+	}
+	ready, err := pollReadable(int(dev.fd.Fd()), pcieStreamPollInterval)
+	backoff.sleep = ready || err != nil
+	return nil
+}
+
+// ReadPacket reads data of the current packet into the buffer. It returns the
+// number of bytes read and whether the end of the packet was reached. If the
+// buffer is smaller than the remainder of the packet, the end of the packet is
+// not reached and the following reads return the rest of the packet. If the
+// packet ends exactly at the end of the buffer, the end of the packet is
+// reported by the following read returning zero bytes.
+func (stream *PCIeDMAStream) ReadPacket(buf []byte) (int, bool, error) {
+	// check if access mode allows reading
+	if (stream.dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return 0, false, ErrNotReadable
+	}
+
+	n, err := stream.transfer("read", buf)
+	if err != nil {
+		return n, false, err
+	}
+	return n, n < len(buf), nil
+}
+
+// transfer performs a single read or write system call and records it in the
+// device's metrics.
+func (stream *PCIeDMAStream) transfer(op string, data []byte) (int, error) {
+	startTime := time.Now()
+	n, err := stream.transferPacket(op, data)
+	stream.dev.recordTransfer(op, len(data), n, err, time.Since(startTime))
+	return n, err
+}
+
+// transferPacket performs a single read or write system call, so that packet
+// boundaries are retained. Interrupted system calls are retried.
+func (stream *PCIeDMAStream) transferPacket(op string,
+	data []byte) (int, error) {
+	dev := stream.dev
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+
+	// make sure device is still open
+	if dev.fd == nil {
+		return 0, newPCIeTransferError(op, dev.devName, 0, len(data), 0,
+			ErrClosed)
+	}
+
+	// start watchdog
+	if watchdog := dev.startWatchdog(op, 0, len(data)); watchdog != nil {
+		defer watchdog.Stop()
+	}
+
+	for {
+		var n int
+		var err error
+		if op == "write" {
+			n, err = syscall.Pwrite(int(dev.fd.Fd()), data, 0)
+		} else {
+			n, err = syscall.Pread(int(dev.fd.Fd()), data, 0)
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, newPCIeTransferError(op, dev.devName, 0, len(data), 0,
+				err)
+		}
+		return n, nil
+	}
+}
+
+// Receive starts a receive loop that delivers packets on the returned channel
+// until the context is done, a read fails or the end of the stream is reached
+// (i.e. the device is closed). Packets larger than the maximum packet size are
+// truncated, empty packets are skipped. If a read fails or the end of the
+// stream is reached, a final packet holding the error (io.EOF for the end of
+// the stream) is delivered. The channel is closed afterwards.
+// The context is checked between reads, a read that is in progress is not
+// interrupted. Packets are read into buffers of the device's buffer pool (see
+// PCIeDMA.BufferPool) and delivered as copies of their exact size.
+func (stream *PCIeDMAStream) Receive(ctx context.Context, maxPacketSize,
+	bufSize int) (<-chan PCIeDMAPacket, error) {
+	// check if access mode allows reading
+	if (stream.dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return nil, ErrNotReadable
+	}
+	if maxPacketSize < 1 || bufSize < 0 {
+		return nil, newPCIeError("start stream receive loop",
+			stream.dev.devName, ErrInvalidArgument)
+	}
+
+	pool, err := stream.dev.BufferPool()
+	if err != nil {
+		return nil, err
+	}
+	bufs := &streamBuffers{pool: pool, size: uint64(maxPacketSize)}
+	if _, err := bufs.get(&bufs.data); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan PCIeDMAPacket, bufSize)
+	go func() {
+		defer close(ch)
+		defer bufs.release()
+
+		var backoff streamBackoff
+		for ctx.Err() == nil {
+			packet, err := stream.receivePacket(bufs)
+			if err == nil && len(packet.Data) == 0 {
+				if err = backoff.wait(stream); err == nil {
+					continue
+				}
+				err = io.EOF
+			}
+			backoff = streamBackoff{}
+			if err != nil {
+				packet = PCIeDMAPacket{Err: err}
+			}
+			select {
+			case ch <- packet:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// streamBuffers holds the pooled buffers of a receive loop.
+type streamBuffers struct {
+	pool    *PCIeDMABufferPool
+	size    uint64
+	data    *PCIeDMABuffer
+	discard *PCIeDMABuffer
+}
+
+// get takes a buffer from the pool if it has not been taken yet and returns
+// its memory.
+func (bufs *streamBuffers) get(buf **PCIeDMABuffer) ([]byte, error) {
+	if *buf == nil {
+		var err error
+		*buf, err = bufs.pool.Get(bufs.size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return (*buf).Bytes()[:bufs.size], nil
+}
+
+// release returns the buffers to the pool.
+func (bufs *streamBuffers) release() {
+	bufs.pool.Put(bufs.data)
+	bufs.pool.Put(bufs.discard)
+}
+
+// receivePacket reads a complete packet into the data buffer and returns a
+// copy of it. Data exceeding the maximum packet size is read into the discard
+// buffer and dropped. An empty packet is returned if the read only marked the
+// end of a packet.
+func (stream *PCIeDMAStream) receivePacket(
+	bufs *streamBuffers) (PCIeDMAPacket, error) {
+	var packet PCIeDMAPacket
+	data, err := bufs.get(&bufs.data)
+	if err != nil {
+		return packet, err
+	}
+	n := 0
+	for {
+		// read next part of the packet. once the maximum packet size is
+		// reached, the rest of the packet is dropped
+		buf := data[n:]
+		if len(buf) == 0 {
+			if buf, err = bufs.get(&bufs.discard); err != nil {
+				return packet, err
+			}
+		}
+
+		nRead, eop, err := stream.ReadPacket(buf)
+		if errors.Is(err, ErrClosed) {
+			return packet, io.EOF
+		}
+		if err != nil {
+			return packet, err
+		}
+
+		// an empty read at the start of a packet only marks the end of an
+		// empty packet
+		if n == 0 && nRead == 0 {
+			return packet, nil
+		}
+
+		if len(data[n:]) == 0 {
+			packet.Truncated = packet.Truncated || nRead > 0
+		} else {
+			n += nRead
+		}
+		if eop {
+			// the data buffer is reused for the next packet
+			packet.Data = append([]byte(nil), data[:n]...)
+			return packet, nil
+		}
+	}
+}
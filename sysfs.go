@@ -0,0 +1,169 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Helper functions to locate PCIExpress devices in sysfs.
+//
+
+package gopcie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// pcieSysfsDevicesDir is the sysfs directory listing all PCIExpress devices.
+const pcieSysfsDevicesDir = "/sys/bus/pci/devices"
+
+// PCIeDeviceFind returns the PCI address (e.g. "0000:01:00.0") of the device
+// matching the function, vendor and device ID.
+func PCIeDeviceFind(functionId, vendorId, deviceId uint) (string, error) {
+	return pcieDeviceFind(functionId, vendorId, deviceId)
+}
+
+// pcieDeviceFind searches sysfs for the device matching the function, vendor
+// and device ID. It returns the PCI address of the device (e.g.
+// "0000:01:00.0"), which is also the name of its sysfs directory.
+func pcieDeviceFind(functionId, vendorId, deviceId uint) (string, error) {
+	// list system devices directory
+	devDirs, err := ioutil.ReadDir(pcieSysfsDevicesDir)
+	if err != nil {
+		return "", newPCIeError("read directory", pcieSysfsDevicesDir, err)
+	}
+
+	// iterate over all devices
+	for _, devDir := range devDirs {
+
+		// not the device we are looking for if directory name does not start
+		// with "0000:"
+		if devDir.Name()[0:5] != "0000:" {
+			continue
+		}
+
+		// get function id and see if it matches the one we are looking for
+		functionIdFound, err := strconv.ParseUint(
+			devDir.Name()[len(devDir.Name())-1:], 10, 32)
+		if err != nil || uint(functionIdFound) != functionId {
+			continue
+		}
+
+		// get vendor id
+		vendorIdFound, err := pcieSysfsReadId(devDir.Name(), "vendor")
+		if err != nil || vendorIdFound != vendorId {
+			continue
+		}
+
+		// get device id
+		deviceIdFound, err := pcieSysfsReadId(devDir.Name(), "device")
+		if err != nil || deviceIdFound != deviceId {
+			continue
+		}
+
+		// all ids matched. found it!
+		return devDir.Name(), nil
+	}
+
+	return "", newPCIeError("find device",
+		fmt.Sprintf("%04x:%04x function %d", vendorId, deviceId, functionId),
+		ErrDeviceNotFound)
+}
+
+// pcieSysfsReadId reads a hexadecimal ID attribute file (e.g. "vendor" or
+// "device") of the device with the specified PCI address.
+func pcieSysfsReadId(devAddr, attr string) (uint, error) {
+	// read attribute file
+	idFilename := filepath.Join(pcieSysfsDevicesDir, devAddr, attr)
+	idFile, err := ioutil.ReadFile(idFilename)
+	if err != nil {
+		return 0, newPCIeError("read pci "+attr+" file", idFilename, err)
+	}
+	idFileStr := string(idFile)
+
+	// attribute file should have only one line and start with "0x"
+	if len(idFileStr) < 3 || idFileStr[0:2] != "0x" ||
+		strings.Index(idFileStr, "\n") != len(idFileStr)-1 {
+		return 0, newPCIeError("parse pci "+attr+" file", idFilename,
+			ErrUnsupported)
+	}
+	idFileStr = idFileStr[0 : len(idFileStr)-1]
+
+	// get id
+	id, err := strconv.ParseUint(idFileStr[2:], 16, 32)
+	if err != nil {
+		return 0, newPCIeError("parse pci "+attr+" file", idFilename, err)
+	}
+	return uint(id), nil
+}
+
+// pcieSysfsIommuGroup returns the IOMMU group number of the device with the
+// specified PCI address.
+func pcieSysfsIommuGroup(devAddr string) (string, error) {
+	linkName := filepath.Join(pcieSysfsDevicesDir, devAddr, "iommu_group")
+	link, err := filepath.EvalSymlinks(linkName)
+	if err != nil {
+		return "", newPCIeError("determine iommu group", linkName, err)
+	}
+	return filepath.Base(link), nil
+}
+
+// pcieDeviceAddrOfDev returns the PCI address of the PCIExpress device a
+// character device (e.g. a DMA device /dev/...) belongs to.
+func pcieDeviceAddrOfDev(devName string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(devName, &stat); err != nil {
+		return "", newPCIeError("stat device", devName, err)
+	}
+	if (stat.Mode & syscall.S_IFMT) != syscall.S_IFCHR {
+		return "", newPCIeError("stat device", devName, syscall.ENODEV)
+	}
+
+	// the sysfs entry of the character device links to its parent device
+	major := (stat.Rdev >> 8) & 0xfff
+	minor := (stat.Rdev & 0xff) | ((stat.Rdev >> 12) & 0xfff00)
+	linkName := fmt.Sprintf("/sys/dev/char/%d:%d/device", major, minor)
+	devDir, err := filepath.EvalSymlinks(linkName)
+	if err != nil {
+		return "", newPCIeError("find pci device of device", devName, err)
+	}
+
+	// walk up the device hierarchy until a pci device is reached
+	for ; devDir != "/" && devDir != "."; devDir = filepath.Dir(devDir) {
+		_, err := pcieSysfsReadId(filepath.Base(devDir), "vendor")
+		if err == nil {
+			return filepath.Base(devDir), nil
+		}
+	}
+	return "", newPCIeError("find pci device of device", devName,
+		ErrDeviceNotFound)
+}
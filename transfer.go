@@ -0,0 +1,268 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Chunked and context-aware DMA transfers. Transfers are split into chunks no
+// larger than the configured maximum transfer size, since some drivers limit
+// the size of a single read or write system call. Interrupted and short chunks
+// are retried. Between two chunks, context-aware transfers are aborted if the
+// context has been canceled or its deadline has passed. A watchdog hook is
+// invoked if a single chunk takes longer than a configurable timeout, which
+// indicates a hung DMA engine.
+//
+
+package gopcie
+
+import (
+	"context"
+	"io"
+	"syscall"
+	"time"
+)
+
+// pcieDMAContextChunkSize is the maximum size of the chunks context-aware
+// transfers are split into.
+const pcieDMAContextChunkSize = 1 << 20
+
+// pcieDMAMaxRetries is the number of times a chunk is retried if it did not
+// transfer any data.
+const pcieDMAMaxRetries = 3
+
+// PCIeDMAWatchdogFunc is invoked when a DMA transfer chunk did not complete
+// within the watchdog timeout. It receives the operation ("read" or "write"),
+// card address and size of the chunk. The function is called from a separate
+// goroutine while the chunk is still in progress.
+type PCIeDMAWatchdogFunc func(op string, addr uint64, size int)
+
+// SetMaxTransferSize sets the maximum number of bytes transferred by a single
+// read or write system call. Larger transfers are split into multiple chunks.
+// A size of zero (the default) does not limit the transfer size.
+func (dev *PCIeDMA) SetMaxTransferSize(size int) error {
+	if size < 0 {
+		return newPCIeError("set max transfer size", dev.devName,
+			ErrInvalidArgument)
+	}
+
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	if size > 0 && uint64(size) < dev.transferAlign {
+		return newPCIeError("set max transfer size", dev.devName,
+			ErrInvalidArgument)
+	}
+	dev.maxTransferSize = size
+	return nil
+}
+
+// SetTransferAlignment sets the card address alignment of chunk boundaries.
+// When a transfer is split into chunks, every chunk except the last one ends on
+// an aligned card address. The alignment must be a power of two and may not be
+// larger than the maximum transfer size. An alignment of zero or one (the
+// default) disables alignment.
+func (dev *PCIeDMA) SetTransferAlignment(align uint64) error {
+	if (align & (align - 1)) != 0 {
+		return newPCIeError("set transfer alignment", dev.devName,
+			ErrInvalidArgument)
+	}
+
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	if dev.maxTransferSize > 0 && align > uint64(dev.maxTransferSize) {
+		return newPCIeError("set transfer alignment", dev.devName,
+			ErrInvalidArgument)
+	}
+	dev.transferAlign = align
+	return nil
+}
+
+// SetWatchdog installs a watchdog hook that is invoked if a single transfer
+// chunk does not complete within the specified timeout. A zero timeout or nil
+// hook disables the watchdog.
+func (dev *PCIeDMA) SetWatchdog(timeout time.Duration,
+	hook PCIeDMAWatchdogFunc) {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	dev.watchdogTimeout = timeout
+	dev.watchdogHook = hook
+}
+
+// WriteContext performs a DMA write transfer like Write, but checks the context
+// between chunks of the transfer. If the context is done, the transfer is
+// aborted and an error wrapping the context's error is returned. Chunks that
+// are already in progress are not interrupted. It returns the number of bytes
+// written.
+func (dev *PCIeDMA) WriteContext(ctx context.Context, addr uint64,
+	data []byte) (int, error) {
+	// check if access mode allows writing
+	if (dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
+		return 0, ErrNotWritable
+	}
+	return dev.transferContext(ctx, "write", addr, data)
+}
+
+// ReadContext performs a DMA read transfer like Read, but checks the context
+// between chunks of the transfer. If the context is done, the transfer is
+// aborted and an error wrapping the context's error is returned. Chunks that
+// are already in progress are not interrupted. It returns the number of bytes
+// read.
+func (dev *PCIeDMA) ReadContext(ctx context.Context, addr uint64,
+	data []byte) (int, error) {
+	// check if access mode allows reading
+	if (dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return 0, ErrNotReadable
+	}
+	return dev.transferContext(ctx, "read", addr, data)
+}
+
+// transferContext performs a chunked DMA transfer that is aborted if the
+// context is done and records it in the device's metrics.
+func (dev *PCIeDMA) transferContext(ctx context.Context, op string,
+	addr uint64, data []byte) (int, error) {
+	startTime := time.Now()
+	n, err := dev.transferChunked(ctx, op, addr, data)
+	dev.recordTransfer(op, len(data), n, err, time.Since(startTime))
+	return n, err
+}
+
+// transferChunked performs a chunked DMA transfer that is aborted if the
+// context is done.
+func (dev *PCIeDMA) transferChunked(ctx context.Context, op string,
+	addr uint64, data []byte) (int, error) {
+	// get chunking configuration
+	dev.mutex.RLock()
+	maxTransferSize := dev.maxTransferSize
+	transferAlign := dev.transferAlign
+	dev.mutex.RUnlock()
+
+	// transfers that can be canceled are split into chunks no larger than the
+	// context chunk size. the background context is never done
+	if ctx.Done() != nil && (maxTransferSize == 0 ||
+		maxTransferSize > pcieDMAContextChunkSize) {
+		maxTransferSize = pcieDMAContextChunkSize
+	}
+
+	nBytesTransferred := 0
+	nRetries := 0
+	for nBytesTransferred < len(data) {
+		// abort transfer if context is done
+		if err := ctx.Err(); err != nil {
+			return nBytesTransferred, newPCIeTransferError(op, dev.devName,
+				addr, len(data), nBytesTransferred, err)
+		}
+
+		// determine chunk size
+		chunkAddr := addr + uint64(nBytesTransferred)
+		chunkSize := pcieDMAChunkSize(chunkAddr, len(data)-nBytesTransferred,
+			maxTransferSize, transferAlign)
+
+		// transfer chunk. the address of the next chunk is advanced by the
+		// number of bytes actually transferred, so short transfers are
+		// continued where they stopped
+		n, err := dev.transferChunk(op, chunkAddr,
+			data[nBytesTransferred:nBytesTransferred+chunkSize])
+		nBytesTransferred += n
+
+		// retry interrupted and short transfers, but give up if no progress
+		// is made anymore
+		if err == nil || err == io.EOF || isTemporarySyscallError(err) {
+			if n > 0 {
+				nRetries = 0
+				continue
+			}
+			if nRetries < pcieDMAMaxRetries {
+				nRetries++
+				continue
+			}
+			err = nil
+		}
+		return nBytesTransferred, newPCIeTransferError(op, dev.devName,
+			addr, len(data), nBytesTransferred, err)
+	}
+	return nBytesTransferred, nil
+}
+
+// pcieDMAChunkSize returns the size of the next chunk of a transfer. If the
+// remaining transfer is larger than the maximum transfer size, it is split so
+// that the chunk ends on an aligned card address.
+func pcieDMAChunkSize(addr uint64, remaining, maxTransferSize int,
+	transferAlign uint64) int {
+	if maxTransferSize == 0 || remaining <= maxTransferSize {
+		return remaining
+	}
+	chunkSize := maxTransferSize
+	if transferAlign > 1 {
+		chunkEnd := (addr + uint64(chunkSize)) &^ (transferAlign - 1)
+		if chunkEnd > addr {
+			chunkSize = int(chunkEnd - addr)
+		}
+	}
+	return chunkSize
+}
+
+// isTemporarySyscallError returns true if the error indicates that the system
+// call was interrupted and should be retried.
+func isTemporarySyscallError(err error) bool {
+	err = unwrapOsError(err)
+	return err == syscall.EINTR || err == syscall.EAGAIN
+}
+
+// transferChunk transfers a single chunk and supervises it with the watchdog.
+func (dev *PCIeDMA) transferChunk(op string, addr uint64,
+	data []byte) (int, error) {
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+
+	// make sure device is still open
+	if dev.fd == nil {
+		return 0, ErrClosed
+	}
+
+	// start watchdog
+	if watchdog := dev.startWatchdog(op, addr, len(data)); watchdog != nil {
+		defer watchdog.Stop()
+	}
+
+	if op == "write" {
+		return dev.fd.WriteAt(data, int64(addr))
+	}
+	return dev.fd.ReadAt(data, int64(addr))
+}
+
+// startWatchdog starts the watchdog for a chunk. It returns nil if the
+// watchdog is disabled. The caller must hold the device mutex.
+func (dev *PCIeDMA) startWatchdog(op string, addr uint64,
+	size int) *time.Timer {
+	if dev.watchdogTimeout <= 0 || dev.watchdogHook == nil {
+		return nil
+	}
+	hook := dev.watchdogHook
+	return time.AfterFunc(dev.watchdogTimeout, func() {
+		hook(op, addr, size)
+	})
+}
@@ -0,0 +1,387 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Userspace I/O (UIO) backend. The device memory regions are memory-mapped
+// from /dev/uioN and interrupts are received by reading the interrupt count
+// from the same device file.
+//
+
+package gopcie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// uioSysfsClassDir is the sysfs directory listing all UIO devices.
+const uioSysfsClassDir = "/sys/class/uio"
+
+// uioPollInterval is the interval in which waiting for an interrupt checks
+// whether the device has been closed.
+const uioPollInterval = 100 * time.Millisecond
+
+// PCIeUIO implements BAR access and interrupt handling for a PCIExpress device
+// bound to a UIO driver (e.g. uio_pci_generic).
+type PCIeUIO struct {
+	fd      int
+	devName string
+
+	// the close mutex protects the file descriptor against being closed while
+	// it is used. waiting for interrupts holds it for at most uioPollInterval
+	// at a time, so that Close does not block
+	closeMutex sync.RWMutex
+	closed     bool
+
+	// interrupt counter bookkeeping and interrupt notification state
+	mutex      sync.Mutex
+	count      uint32
+	countValid bool
+	missed     uint64
+
+	// interrupt notification goroutine
+	notifyStop chan struct{}
+	notifyWg   sync.WaitGroup
+}
+
+// UIOOpen opens the UIO device of a PCIExpress device. The function expects
+// the function, vendor and device ID of the device to be opened.
+func UIOOpen(functionId, vendorId, deviceId uint) (*PCIeUIO, error) {
+	// find the device in sysfs
+	devAddr, err := pcieDeviceFind(functionId, vendorId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the uio device attached to the PCIExpress device
+	uioDirName := filepath.Join(pcieSysfsDevicesDir, devAddr, "uio")
+	uioDirs, err := ioutil.ReadDir(uioDirName)
+	if err != nil || len(uioDirs) == 0 {
+		return nil, newPCIeError("find uio device", uioDirName,
+			ErrDeviceNotFound)
+	}
+
+	return UIOOpenDev(filepath.Join("/dev", uioDirs[0].Name()))
+}
+
+// UIOOpenDev opens a UIO device. The function expects the device name (i.e.
+// /dev/uioN).
+func UIOOpenDev(devName string) (*PCIeUIO, error) {
+	fd, err := syscall.Open(devName, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, newPCIeError("open device", devName, err)
+	}
+	return &PCIeUIO{
+		fd:      fd,
+		devName: devName,
+	}, nil
+}
+
+// Close closes the UIO device. BARs obtained from the device remain valid until
+// they are closed themselves. Closing an already closed device has no effect.
+func (dev *PCIeUIO) Close() error {
+	// once the device is marked closed, the file descriptor is not used
+	// anymore
+	dev.closeMutex.Lock()
+	if dev.closed {
+		dev.closeMutex.Unlock()
+		return nil
+	}
+	dev.closed = true
+	dev.closeMutex.Unlock()
+
+	// stop interrupt notification goroutine
+	dev.mutex.Lock()
+	notifyStop := dev.notifyStop
+	dev.notifyStop = nil
+	dev.mutex.Unlock()
+	if notifyStop != nil {
+		close(notifyStop)
+		dev.notifyWg.Wait()
+	}
+
+	if err := syscall.Close(dev.fd); err != nil {
+		return newPCIeError("close device", dev.devName, err)
+	}
+	return nil
+}
+
+// acquire locks the file descriptor against being closed. It returns an error
+// if the device has been closed.
+func (dev *PCIeUIO) acquire(op string) error {
+	dev.closeMutex.RLock()
+	if dev.closed {
+		dev.closeMutex.RUnlock()
+		return newPCIeError(op, dev.devName, ErrClosed)
+	}
+	return nil
+}
+
+// release unlocks the file descriptor locked by acquire.
+func (dev *PCIeUIO) release() {
+	dev.closeMutex.RUnlock()
+}
+
+// BAROpen memory-maps a memory region of the UIO device. For uio_pci_generic
+// and most other PCI UIO drivers, the map ID corresponds to the BAR ID. The
+// returned PCIeBAR is used exactly like one returned by PCIeBAROpen.
+func (dev *PCIeUIO) BAROpen(mapId uint) (*PCIeBAR, error) {
+	// read map size from sysfs
+	sizeFilename := filepath.Join(uioSysfsClassDir, filepath.Base(dev.devName),
+		"maps", fmt.Sprintf("map%d", mapId), "size")
+	sizeFile, err := ioutil.ReadFile(sizeFilename)
+	if os.IsNotExist(err) {
+		return nil, newPCIeError("read uio map size", sizeFilename,
+			ErrBARNotFound)
+	}
+	if err != nil {
+		return nil, newPCIeError("read uio map size", sizeFilename, err)
+	}
+	size, err := HexStringToInt(strings.TrimSpace(string(sizeFile)))
+	if err != nil || size == 0 {
+		return nil, newPCIeError("parse uio map size", sizeFilename,
+			ErrBARNotFound)
+	}
+
+	if err := dev.acquire("open BAR"); err != nil {
+		return nil, err
+	}
+	defer dev.release()
+
+	// the map to be memory-mapped is selected via the offset, which is the map
+	// id times the page size
+	bar, err := syscall.Mmap(dev.fd, int64(mapId)*int64(os.Getpagesize()),
+		int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, newPCIeError("memory-map BAR", dev.devName, err)
+	}
+
+	// the BAR gets its own reference to the device file, so that closing the
+	// BAR and the device is independent of each other
+	fd, err := syscall.Dup(dev.fd)
+	if err != nil {
+		syscall.Munmap(bar)
+		return nil, newPCIeError("duplicate file descriptor", dev.devName, err)
+	}
+
+	return newPCIeBAR(os.NewFile(uintptr(fd), dev.devName), dev.devName, bar),
+		nil
+}
+
+// EnableIRQ (re-)enables the interrupt. Drivers such as uio_pci_generic mask
+// the interrupt when it fires, so it has to be re-enabled after every
+// interrupt before the next one can be received.
+func (dev *PCIeUIO) EnableIRQ() error {
+	return dev.writeIRQControl(1)
+}
+
+// DisableIRQ disables the interrupt.
+func (dev *PCIeUIO) DisableIRQ() error {
+	return dev.writeIRQControl(0)
+}
+
+// writeIRQControl writes the interrupt control value to the uio device file.
+func (dev *PCIeUIO) writeIRQControl(value uint32) error {
+	if err := dev.acquire("write interrupt control"); err != nil {
+		return err
+	}
+	defer dev.release()
+
+	var buf [4]byte
+	*(*uint32)(unsafe.Pointer(&buf[0])) = value
+	n, err := syscall.Write(dev.fd, buf[:])
+	if err == nil && n != len(buf) {
+		err = ErrShortTransfer
+	}
+	if err != nil {
+		return newPCIeError("write interrupt control", dev.devName, err)
+	}
+	return nil
+}
+
+// Wait blocks until an interrupt is received or the timeout expires. A negative
+// timeout waits forever. It returns the total number of interrupts the device
+// has received so far. If the timeout expires, ErrTimeout is returned. If the
+// device is closed while waiting, an error wrapping ErrClosed is returned. The
+// interrupt is not re-enabled automatically, call EnableIRQ before Wait if the
+// driver requires it.
+func (dev *PCIeUIO) Wait(timeout time.Duration) (uint32, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		// wait in slices of the poll interval, so that the device can be
+		// closed in between
+		pollTimeout := uioPollInterval
+		if timeout >= 0 && time.Until(deadline) < pollTimeout {
+			pollTimeout = time.Until(deadline)
+			if pollTimeout < 0 {
+				pollTimeout = 0
+			}
+		}
+
+		count, ready, err := dev.waitPoll(pollTimeout)
+		if err != nil {
+			return 0, err
+		}
+		if ready {
+			return count, nil
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// waitPoll waits for an interrupt for at most the specified timeout. If an
+// interrupt is received, it returns true and the interrupt count.
+func (dev *PCIeUIO) waitPoll(timeout time.Duration) (uint32, bool, error) {
+	if err := dev.acquire("wait for interrupt"); err != nil {
+		return 0, false, err
+	}
+	defer dev.release()
+
+	// wait for interrupt
+	ready, err := pollReadable(dev.fd, timeout)
+	if err != nil {
+		return 0, false, newPCIeError("wait for interrupt", dev.devName, err)
+	}
+	if !ready {
+		return 0, false, nil
+	}
+
+	// read interrupt count
+	var buf [4]byte
+	n, err := syscall.Read(dev.fd, buf[:])
+	if err == nil && n != len(buf) {
+		err = ErrShortTransfer
+	}
+	if err != nil {
+		return 0, false, newPCIeError("read interrupt count", dev.devName, err)
+	}
+	count := *(*uint32)(unsafe.Pointer(&buf[0]))
+
+	// interrupts that occurred between two reads are counted as missed
+	dev.mutex.Lock()
+	if dev.countValid && count-dev.count > 1 {
+		dev.missed += uint64(count - dev.count - 1)
+	}
+	dev.count = count
+	dev.countValid = true
+	dev.mutex.Unlock()
+
+	return count, true, nil
+}
+
+// Notify starts delivering interrupts on the returned channel. Each value is
+// the total number of interrupts the device has received so far. The interrupt
+// is re-enabled automatically after each received interrupt. If the channel is
+// full, the interrupt is dropped and counted as missed. The channel is closed
+// when the device is closed. Notify must not be used concurrently with Wait.
+// Once notification has been started, further calls return an error wrapping
+// ErrBusy.
+func (dev *PCIeUIO) Notify(bufSize int) (<-chan uint32, error) {
+	if err := dev.acquire("start interrupt notification"); err != nil {
+		return nil, err
+	}
+	dev.mutex.Lock()
+	if dev.notifyStop != nil {
+		dev.mutex.Unlock()
+		dev.release()
+		return nil, newPCIeError("start interrupt notification", dev.devName,
+			ErrBusy)
+	}
+	dev.notifyStop = make(chan struct{})
+	notifyStop := dev.notifyStop
+	dev.mutex.Unlock()
+	dev.release()
+
+	// enable interrupt before waiting for the first one. if that fails,
+	// notification may be started again
+	if err := dev.EnableIRQ(); err != nil {
+		dev.mutex.Lock()
+		if dev.notifyStop == notifyStop {
+			dev.notifyStop = nil
+		}
+		dev.mutex.Unlock()
+		return nil, err
+	}
+
+	ch := make(chan uint32, bufSize)
+	dev.notifyWg.Add(1)
+	go func() {
+		defer dev.notifyWg.Done()
+		defer close(ch)
+		for {
+			select {
+			case <-notifyStop:
+				return
+			default:
+			}
+
+			count, err := dev.Wait(uioPollInterval)
+			if err == ErrTimeout {
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			select {
+			case ch <- count:
+			default:
+				dev.mutex.Lock()
+				dev.missed++
+				dev.mutex.Unlock()
+			}
+
+			if err := dev.EnableIRQ(); err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Missed returns the number of interrupts that occurred but were not delivered
+// to the application, either because they occurred while no one was waiting or
+// because the notification channel was full.
+func (dev *PCIeUIO) Missed() uint64 {
+	dev.mutex.Lock()
+	defer dev.mutex.Unlock()
+	return dev.missed
+}
@@ -0,0 +1,552 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// io_uring backend for DMA transfers. Batches of transfer requests are
+// submitted to the kernel with a single system call, which reduces the per
+// transfer overhead for high rates of small transfers. The device file is
+// registered with the ring and transfers from/to registered buffers avoid
+// pinning the buffer pages on every transfer. If io_uring is not available
+// (kernel older than 5.1 or disabled), transfers fall back to pread/pwrite.
+//
+
+package gopcie
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// io_uring constants
+const (
+	uringOpReadv      = 1
+	uringOpWritev     = 2
+	uringOpReadFixed  = 4
+	uringOpWriteFixed = 5
+
+	uringSqeFixedFile = 1 << 0
+
+	uringEnterGetEvents = 1 << 0
+
+	uringRegisterBuffers   = 0
+	uringUnregisterBuffers = 1
+	uringRegisterFiles     = 2
+
+	uringOffSqRing = 0
+	uringOffCqRing = 0x8000000
+	uringOffSqes   = 0x10000000
+)
+
+// struct io_sqring_offsets
+type uringSqOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+// struct io_cqring_offsets
+type uringCqOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+// struct io_uring_params
+type uringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCpu, sqThreadIdle uint32
+	features, wqFd                                         uint32
+	resv                                                   [3]uint32
+	sqOff                                                  uringSqOffsets
+	cqOff                                                  uringCqOffsets
+}
+
+// struct io_uring_sqe
+type uringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// struct io_uring_cqe
+type uringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// PCIeDMAUring performs batches of DMA transfers via io_uring.
+type PCIeDMAUring struct {
+	// the mutex serializes batches, since the ring is not safe for concurrent
+	// use
+	mutex sync.Mutex
+	dev   *PCIeDMA
+
+	// ring file descriptor. -1 if io_uring is not available and transfers
+	// fall back to pread/pwrite
+	fd      int
+	entries uint32
+
+	// mapped ring memory
+	sqRing []byte
+	cqRing []byte
+	sqes   []byte
+
+	// pointers into mapped ring memory
+	sqTail *uint32
+	sqMask uint32
+	cqHead *uint32
+	cqTail *uint32
+	cqMask uint32
+	cqOff  uint32
+
+	// io vectors of the current batch, one per submission queue entry
+	iovecs []syscall.Iovec
+
+	// registered buffers and whether device file has been registered
+	buffers   [][]byte
+	fixedFile bool
+
+	// error that left the ring in an unknown state. once set, all transfers
+	// fail with it
+	err    error
+	closed bool
+}
+
+// UringOpen creates an io_uring with the specified number of submission queue
+// entries for the device. If io_uring is not available, the returned ring
+// performs transfers via pread/pwrite (see UsesUring).
+func (dev *PCIeDMA) UringOpen(entries int) (*PCIeDMAUring, error) {
+	if entries < 1 {
+		return nil, newPCIeError("open io_uring", dev.devName,
+			ErrInvalidArgument)
+	}
+
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+	if dev.fd == nil {
+		return nil, newPCIeError("open io_uring", dev.devName, ErrClosed)
+	}
+
+	ring := &PCIeDMAUring{dev: dev, fd: -1}
+
+	// set up ring
+	var params uringParams
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries),
+		uintptr(unsafe.Pointer(&params)), 0)
+	if errno == syscall.ENOSYS || errno == syscall.EPERM ||
+		errno == syscall.EACCES {
+		// io_uring not supported or disabled, fall back to pread/pwrite
+		return ring, nil
+	}
+	if errno != 0 {
+		return nil, newPCIeError("open io_uring", dev.devName, errno)
+	}
+	ring.fd = int(fd)
+	ring.entries = params.sqEntries
+
+	if err := ring.mmap(&params); err != nil {
+		ring.Close()
+		return nil, newPCIeError("open io_uring", dev.devName, err)
+	}
+
+	// register device file. if that fails, the file descriptor is passed
+	// with every transfer
+	devFd := int32(dev.fd.Fd())
+	if ring.register(uringRegisterFiles, unsafe.Pointer(&devFd), 1) == nil {
+		ring.fixedFile = true
+	}
+
+	return ring, nil
+}
+
+// mmap maps the submission and completion queues into memory.
+func (ring *PCIeDMAUring) mmap(params *uringParams) error {
+	var err error
+
+	ring.sqRing, err = syscall.Mmap(ring.fd, uringOffSqRing,
+		int(params.sqOff.array+params.sqEntries*4),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return err
+	}
+	cqeSize := uint32(unsafe.Sizeof(uringCqe{}))
+	ring.cqRing, err = syscall.Mmap(ring.fd, uringOffCqRing,
+		int(params.cqOff.cqes+params.cqEntries*cqeSize),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return err
+	}
+	ring.sqes, err = syscall.Mmap(ring.fd, uringOffSqes,
+		int(params.sqEntries*uint32(unsafe.Sizeof(uringSqe{}))),
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return err
+	}
+
+	ring.sqTail = ring.ringUint32(ring.sqRing, params.sqOff.tail)
+	ring.sqMask = *ring.ringUint32(ring.sqRing, params.sqOff.ringMask)
+	ring.cqHead = ring.ringUint32(ring.cqRing, params.cqOff.head)
+	ring.cqTail = ring.ringUint32(ring.cqRing, params.cqOff.tail)
+	ring.cqMask = *ring.ringUint32(ring.cqRing, params.cqOff.ringMask)
+	ring.cqOff = params.cqOff.cqes
+
+	// submission queue entries are always used in ring order, so the
+	// indirection array is set up once
+	for i := uint32(0); i < params.sqEntries; i++ {
+		*ring.ringUint32(ring.sqRing, params.sqOff.array+i*4) = i
+	}
+
+	ring.iovecs = make([]syscall.Iovec, params.sqEntries)
+	return nil
+}
+
+// ringUint32 returns a pointer to a 32 bit value in mapped ring memory.
+func (ring *PCIeDMAUring) ringUint32(mem []byte, off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&mem[off]))
+}
+
+// register performs an io_uring_register system call.
+func (ring *PCIeDMAUring) register(opcode int, arg unsafe.Pointer,
+	nrArgs int) error {
+	_, _, errno := syscall.Syscall6(sysIoUringRegister, uintptr(ring.fd),
+		uintptr(opcode), uintptr(arg), uintptr(nrArgs), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// UsesUring returns true if transfers are performed via io_uring and false if
+// they fall back to pread/pwrite.
+func (ring *PCIeDMAUring) UsesUring() bool {
+	return ring.fd >= 0
+}
+
+// RegisterBuffers registers buffers with the ring. Transfers whose data lies
+// entirely within a registered buffer do not have to pin the buffer's pages on
+// every transfer. Previously registered buffers are unregistered. The buffers
+// must not be freed while they are registered. Typically, the buffers are
+// allocated via DMABufferAlloc. If io_uring is not available, the function has
+// no effect.
+func (ring *PCIeDMAUring) RegisterBuffers(buffers [][]byte) error {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+	if ring.closed {
+		return newPCIeError("register io_uring buffers", ring.dev.devName,
+			ErrClosed)
+	}
+	if !ring.UsesUring() {
+		return nil
+	}
+
+	// unregister previously registered buffers
+	if len(ring.buffers) > 0 {
+		if err := ring.register(uringUnregisterBuffers, nil, 0); err != nil {
+			return newPCIeError("unregister io_uring buffers",
+				ring.dev.devName, err)
+		}
+		ring.buffers = nil
+	}
+	if len(buffers) == 0 {
+		return nil
+	}
+
+	iovecs := make([]syscall.Iovec, len(buffers))
+	for i, buf := range buffers {
+		if len(buf) == 0 {
+			return newPCIeError("register io_uring buffers", ring.dev.devName,
+				ErrInvalidArgument)
+		}
+		iovecs[i].Base = &buf[0]
+		iovecs[i].SetLen(len(buf))
+	}
+	err := ring.register(uringRegisterBuffers, unsafe.Pointer(&iovecs[0]),
+		len(iovecs))
+	if err != nil {
+		return newPCIeError("register io_uring buffers", ring.dev.devName, err)
+	}
+	ring.buffers = buffers
+	return nil
+}
+
+// registeredBuffer returns the index of the registered buffer containing the
+// data, or -1 if the data does not lie within a registered buffer.
+func (ring *PCIeDMAUring) registeredBuffer(data []byte) int {
+	start := uintptr(unsafe.Pointer(&data[0]))
+	end := start + uintptr(len(data))
+	for i, buf := range ring.buffers {
+		bufStart := uintptr(unsafe.Pointer(&buf[0]))
+		if start >= bufStart && end <= bufStart+uintptr(len(buf)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Transfer performs a batch of transfer requests and waits for all of them to
+// complete. Up to the number of submission queue entries, requests are
+// submitted with a single system call and may be processed by the driver in
+// any order. The returned completions are in the order of the requests.
+// Transfers are not split into chunks (see SetMaxTransferSize). An error is
+// only returned if the ring itself failed; errors of individual transfers are
+// reported in their completions.
+func (ring *PCIeDMAUring) Transfer(reqs []*PCIeDMARequest) ([]PCIeDMACompletion,
+	error) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	if ring.closed {
+		return nil, newPCIeError("transfer", ring.dev.devName, ErrClosed)
+	}
+	if ring.err != nil {
+		return nil, newPCIeError("transfer", ring.dev.devName, ring.err)
+	}
+
+	completions := make([]PCIeDMACompletion, len(reqs))
+
+	// fall back to pread/pwrite if io_uring is not available
+	if !ring.UsesUring() {
+		for i, req := range reqs {
+			completions[i].Request = req
+			if req.Op == PCIE_ACCESS_WRITE {
+				completions[i].Transferred, completions[i].Err =
+					ring.dev.WriteAt(req.Data, int64(req.Addr))
+			} else if req.Op == PCIE_ACCESS_READ {
+				completions[i].Transferred, completions[i].Err =
+					ring.dev.ReadAt(req.Data, int64(req.Addr))
+			} else {
+				completions[i].Err = newPCIeError("transfer", ring.dev.devName,
+					ErrInvalidArgument)
+			}
+		}
+		return completions, nil
+	}
+
+	// make sure device is not closed while transfers are in progress
+	ring.dev.mutex.RLock()
+	defer ring.dev.mutex.RUnlock()
+	if ring.dev.fd == nil {
+		return nil, newPCIeError("transfer", ring.dev.devName, ErrClosed)
+	}
+
+	// check requests. invalid requests are completed right away, all others
+	// are submitted
+	pending := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		completions[i].Request = req
+		switch {
+		case req.Op == PCIE_ACCESS_WRITE &&
+			(ring.dev.accessMode&PCIE_ACCESS_WRITE) == 0:
+			completions[i].Err = ErrNotWritable
+		case req.Op == PCIE_ACCESS_READ &&
+			(ring.dev.accessMode&PCIE_ACCESS_READ) == 0:
+			completions[i].Err = ErrNotReadable
+		case req.Op != PCIE_ACCESS_WRITE && req.Op != PCIE_ACCESS_READ:
+			completions[i].Err = newPCIeError("transfer", ring.dev.devName,
+				ErrInvalidArgument)
+		case len(req.Data) > 0:
+			pending = append(pending, i)
+		}
+	}
+
+	// submit requests in batches no larger than the submission queue
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > int(ring.entries) {
+			n = int(ring.entries)
+		}
+		if err := ring.transferBatch(reqs, pending[:n], completions); err != nil {
+			return nil, newPCIeError("transfer", ring.dev.devName, err)
+		}
+		pending = pending[n:]
+	}
+
+	runtime.KeepAlive(reqs)
+	return completions, nil
+}
+
+// transferBatch submits the requests with the specified indices and waits for
+// their completion. The number of requests may not exceed the number of
+// submission queue entries. If submitting fails before the kernel consumed any
+// of the requests, they are removed from the submission queue again and the
+// ring remains usable. If it fails while requests are in flight, their
+// completions can no longer be matched to requests, so the ring is marked
+// broken and all further transfers fail with the error.
+func (ring *PCIeDMAUring) transferBatch(reqs []*PCIeDMARequest, batch []int,
+	completions []PCIeDMACompletion) error {
+	// fill submission queue entries
+	tail := atomic.LoadUint32(ring.sqTail)
+	for i, reqIdx := range batch {
+		req := reqs[reqIdx]
+		idx := tail & ring.sqMask
+		sqe := (*uringSqe)(unsafe.Pointer(
+			&ring.sqes[uintptr(idx)*unsafe.Sizeof(uringSqe{})]))
+		*sqe = uringSqe{
+			off:      req.Addr,
+			userData: uint64(i),
+		}
+
+		if ring.fixedFile {
+			sqe.flags = uringSqeFixedFile
+		} else {
+			sqe.fd = int32(ring.dev.fd.Fd())
+		}
+
+		if bufIdx := ring.registeredBuffer(req.Data); bufIdx >= 0 {
+			// registered buffer
+			sqe.opcode = uringOpReadFixed
+			if req.Op == PCIE_ACCESS_WRITE {
+				sqe.opcode = uringOpWriteFixed
+			}
+			sqe.addr = uint64(uintptr(unsafe.Pointer(&req.Data[0])))
+			sqe.len = uint32(len(req.Data))
+			sqe.bufIndex = uint16(bufIdx)
+		} else {
+			// regular buffer
+			sqe.opcode = uringOpReadv
+			if req.Op == PCIE_ACCESS_WRITE {
+				sqe.opcode = uringOpWritev
+			}
+			ring.iovecs[i].Base = &req.Data[0]
+			ring.iovecs[i].SetLen(len(req.Data))
+			sqe.addr = uint64(uintptr(unsafe.Pointer(&ring.iovecs[i])))
+			sqe.len = 1
+		}
+
+		tail++
+	}
+	atomic.StoreUint32(ring.sqTail, tail)
+
+	// submit requests and wait for completions
+	startTime := time.Now()
+	nSubmitted := 0
+	nCompleted := 0
+	for nCompleted < len(batch) {
+		n, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(ring.fd),
+			uintptr(len(batch)-nSubmitted), 1, uringEnterGetEvents, 0, 0)
+		if errno != 0 && errno != syscall.EINTR && errno != syscall.EAGAIN &&
+			errno != syscall.EBUSY {
+			if nSubmitted == 0 {
+				atomic.StoreUint32(ring.sqTail, tail-uint32(len(batch)))
+			} else {
+				ring.err = errno
+			}
+			return errno
+		}
+		if errno == 0 {
+			nSubmitted += int(n)
+		}
+
+		// reap completions
+		head := atomic.LoadUint32(ring.cqHead)
+		cqTail := atomic.LoadUint32(ring.cqTail)
+		for ; head != cqTail; head++ {
+			cqe := (*uringCqe)(unsafe.Pointer(&ring.cqRing[uintptr(ring.cqOff)+
+				uintptr(head&ring.cqMask)*unsafe.Sizeof(uringCqe{})]))
+			req := reqs[batch[cqe.userData]]
+			completion := &completions[batch[cqe.userData]]
+
+			op := "read"
+			if req.Op == PCIE_ACCESS_WRITE {
+				op = "write"
+			}
+			if cqe.res < 0 {
+				completion.Err = newPCIeTransferError(op, ring.dev.devName,
+					req.Addr, len(req.Data), 0, syscall.Errno(-cqe.res))
+			} else {
+				completion.Transferred = int(cqe.res)
+				if completion.Transferred < len(req.Data) {
+					completion.Err = newPCIeTransferError(op, ring.dev.devName,
+						req.Addr, len(req.Data), completion.Transferred, nil)
+				}
+			}
+
+			// record transfer in the device's metrics. the device mutex is
+			// already held by Transfer
+			if ring.dev.metrics != nil {
+				ring.dev.metrics.record(op, len(req.Data),
+					completion.Transferred, completion.Err,
+					time.Since(startTime))
+			}
+			nCompleted++
+		}
+		atomic.StoreUint32(ring.cqHead, head)
+	}
+
+	runtime.KeepAlive(ring.iovecs)
+	return nil
+}
+
+// Close releases the ring. Registered buffers are unregistered. Closing an
+// already closed ring has no effect.
+func (ring *PCIeDMAUring) Close() error {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	if ring.closed {
+		return nil
+	}
+	ring.closed = true
+	if ring.fd < 0 {
+		return nil
+	}
+
+	for _, mem := range [][]byte{ring.sqes, ring.cqRing, ring.sqRing} {
+		if mem != nil {
+			syscall.Munmap(mem)
+		}
+	}
+	ring.sqes, ring.cqRing, ring.sqRing = nil, nil, nil
+	ring.buffers = nil
+
+	// closing the ring also unregisters files and buffers
+	err := syscall.Close(ring.fd)
+	ring.fd = -1
+	if err != nil {
+		return newPCIeError("close io_uring", ring.dev.devName, err)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Package benchreport reports the results of the benchmark utilities. Results
+// hold throughput and latency statistics of a benchmark run and are written as
+// human-readable text, CSV or JSON (e.g. for plotting).
+//
+
+package benchreport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{"text", "csv", "json"}
+
+// Result is the result of a benchmark run.
+type Result struct {
+	Test       string        `json:"test"`    // e.g. "write" or "read-latency"
+	Op         string        `json:"op"`      // e.g. "read" or "write"
+	Size       int           `json:"size"`    // transfer or access size
+	Workers    int           `json:"workers"` // number of concurrent workers
+	Operations uint64        `json:"operations"`
+	Bytes      uint64        `json:"bytes"`
+	Errors     uint64        `json:"errors"`
+	Duration   time.Duration `json:"duration_ns"`
+
+	// throughput
+	OpsPerSec float64 `json:"ops_per_sec"`
+	Gbps      float64 `json:"gbps"`
+
+	// latency statistics
+	LatencyMin  time.Duration `json:"latency_min_ns"`
+	LatencyMean time.Duration `json:"latency_mean_ns"`
+	LatencyP50  time.Duration `json:"latency_p50_ns"`
+	LatencyP90  time.Duration `json:"latency_p90_ns"`
+	LatencyP99  time.Duration `json:"latency_p99_ns"`
+	LatencyP999 time.Duration `json:"latency_p999_ns"`
+	LatencyMax  time.Duration `json:"latency_max_ns"`
+
+	// Interrupted is true if the run was interrupted before completion.
+	Interrupted bool `json:"interrupted"`
+}
+
+// NewResult creates a result of a benchmark run from the number of transferred
+// bytes, the number of errors, the duration of the run and the latencies of
+// all operations.
+func NewResult(test, op string, size, workers int, bytes, errors uint64,
+	duration time.Duration, latencies *Histogram) Result {
+	result := Result{
+		Test:        test,
+		Op:          op,
+		Size:        size,
+		Workers:     workers,
+		Operations:  latencies.Count(),
+		Bytes:       bytes,
+		Errors:      errors,
+		Duration:    duration,
+		LatencyMin:  latencies.Min(),
+		LatencyMean: latencies.Mean(),
+		LatencyP50:  latencies.Percentile(50),
+		LatencyP90:  latencies.Percentile(90),
+		LatencyP99:  latencies.Percentile(99),
+		LatencyP999: latencies.Percentile(99.9),
+		LatencyMax:  latencies.Max(),
+	}
+	if duration > 0 {
+		result.OpsPerSec = float64(result.Operations) / duration.Seconds()
+		result.Gbps = 8 * float64(bytes) / duration.Seconds() / 1e9
+	}
+	return result
+}
+
+// Reporter writes benchmark results.
+type Reporter interface {
+	// Report writes a result.
+	Report(result Result) error
+	// Close writes any pending output. It does not close the underlying
+	// writer.
+	Close() error
+}
+
+// NewReporter creates a reporter writing results in the specified format
+// (see Formats) to w.
+func NewReporter(w io.Writer, format string) (Reporter, error) {
+	switch format {
+	case "text":
+		return &textReporter{w: w}, nil
+	case "csv":
+		return &csvReporter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonReporter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %s", format)
+}
+
+// textReporter writes results as a human-readable table.
+type textReporter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func (r *textReporter) Report(result Result) error {
+	if !r.headerWritten {
+		_, err := fmt.Fprintf(r.w, "%-16s %-5s %10s %4s %12s %12s %10s "+
+			"%10s %10s %10s %10s %10s %10s\n", "test", "op", "size", "wrk",
+			"ops/s", "Gbps", "min", "mean", "p50", "p99", "p99.9", "max",
+			"errors")
+		if err != nil {
+			return err
+		}
+		r.headerWritten = true
+	}
+
+	note := ""
+	if result.Interrupted {
+		note = " (interrupted)"
+	}
+	_, err := fmt.Fprintf(r.w, "%-16s %-5s %10s %4d %12.0f %12.3f %10s "+
+		"%10s %10s %10s %10s %10s %10d%s\n", result.Test, result.Op,
+		fmt.Sprintf("0x%x", result.Size), result.Workers, result.OpsPerSec,
+		result.Gbps, formatLatency(result.LatencyMin),
+		formatLatency(result.LatencyMean), formatLatency(result.LatencyP50),
+		formatLatency(result.LatencyP99), formatLatency(result.LatencyP999),
+		formatLatency(result.LatencyMax), result.Errors, note)
+	return err
+}
+
+func (r *textReporter) Close() error {
+	return nil
+}
+
+// formatLatency formats a latency with a precision suitable for a table.
+func formatLatency(latency time.Duration) string {
+	switch {
+	case latency < time.Microsecond:
+		return latency.String()
+	case latency < time.Millisecond:
+		return latency.Round(10 * time.Nanosecond).String()
+	case latency < time.Second:
+		return latency.Round(10 * time.Microsecond).String()
+	}
+	return latency.Round(10 * time.Millisecond).String()
+}
+
+// csvReporter writes results as comma-separated values with a header line.
+type csvReporter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (r *csvReporter) Report(result Result) error {
+	if !r.headerWritten {
+		r.w.Write([]string{"test", "op", "size", "workers", "operations",
+			"bytes", "errors", "duration_ns", "ops_per_sec", "gbps",
+			"latency_min_ns", "latency_mean_ns", "latency_p50_ns",
+			"latency_p90_ns", "latency_p99_ns", "latency_p999_ns",
+			"latency_max_ns", "interrupted"})
+		r.headerWritten = true
+	}
+
+	formatUint := func(value uint64) string {
+		return strconv.FormatUint(value, 10)
+	}
+	formatDuration := func(value time.Duration) string {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	formatFloat := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	r.w.Write([]string{
+		result.Test,
+		result.Op,
+		strconv.Itoa(result.Size),
+		strconv.Itoa(result.Workers),
+		formatUint(result.Operations),
+		formatUint(result.Bytes),
+		formatUint(result.Errors),
+		formatDuration(result.Duration),
+		formatFloat(result.OpsPerSec),
+		formatFloat(result.Gbps),
+		formatDuration(result.LatencyMin),
+		formatDuration(result.LatencyMean),
+		formatDuration(result.LatencyP50),
+		formatDuration(result.LatencyP90),
+		formatDuration(result.LatencyP99),
+		formatDuration(result.LatencyP999),
+		formatDuration(result.LatencyMax),
+		strconv.FormatBool(result.Interrupted),
+	})
+
+	// flush each line, so that results are not lost if the benchmark is
+	// aborted
+	r.w.Flush()
+	return r.w.Error()
+}
+
+func (r *csvReporter) Close() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// jsonReporter writes results as a JSON array.
+type jsonReporter struct {
+	w       io.Writer
+	nResult int
+}
+
+func (r *jsonReporter) Report(result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if r.nResult == 0 {
+		sep = "[\n  "
+	}
+	r.nResult++
+	_, err = fmt.Fprintf(r.w, "%s%s", sep, data)
+	return err
+}
+
+func (r *jsonReporter) Close() error {
+	end := "\n]\n"
+	if r.nResult == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(r.w, end)
+	return err
+}
+
+// ParseSizes parses a comma-separated list of hex sizes (e.g. transfer sizes).
+// An element min-max expands to all powers of two between min and max, plus min
+// and max themselves.
+func ParseSizes(sizesStr string) ([]int, error) {
+	var sizes []int
+	for _, elem := range strings.Split(sizesStr, ",") {
+		bounds := strings.SplitN(elem, "-", 2)
+		var values []int
+		for _, bound := range bounds {
+			value, err := gopcie.HexStringToInt(strings.TrimSpace(bound))
+			if err != nil || value == 0 || value > 1<<40 {
+				return nil, fmt.Errorf("invalid size %s", elem)
+			}
+			values = append(values, int(value))
+		}
+		if len(values) == 1 {
+			sizes = append(sizes, values[0])
+			continue
+		}
+
+		min, max := values[0], values[1]
+		if min > max {
+			return nil, fmt.Errorf("invalid size sweep %s", elem)
+		}
+		sizes = append(sizes, min)
+		for size := 1; size < max; size *= 2 {
+			if size > min {
+				sizes = append(sizes, size)
+			}
+		}
+		if max > min {
+			sizes = append(sizes, max)
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,193 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Latency histogram with bounded memory. Latencies are recorded in buckets of
+// logarithmically increasing width with a relative error below 1%, so that
+// percentiles of long benchmark runs can be determined without keeping all
+// samples.
+//
+
+package benchreport
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogramSubBits is the number of bits selecting the sub-bucket within a
+// power of two. 128 sub-buckets bound the relative error to 1/128.
+const histogramSubBits = 7
+
+// histogramSubBuckets is the number of sub-buckets per power of two.
+const histogramSubBuckets = 1 << histogramSubBits
+
+// Histogram records latencies. The zero value is an empty histogram.
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// Record records a latency. Negative latencies are recorded as zero.
+func (h *Histogram) Record(latency time.Duration) {
+	h.RecordN(latency, 1)
+}
+
+// RecordN records a latency n times, e.g. the mean latency of a batch of n
+// operations that cannot be timed individually.
+func (h *Histogram) RecordN(latency time.Duration, n uint64) {
+	if n == 0 {
+		return
+	}
+	if latency < 0 {
+		latency = 0
+	}
+
+	idx := histogramIndex(uint64(latency))
+	if idx >= len(h.counts) {
+		counts := make([]uint64, idx+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[idx] += n
+
+	if h.count == 0 || latency < h.min {
+		h.min = latency
+	}
+	if latency > h.max {
+		h.max = latency
+	}
+	h.count += n
+	h.sum += latency * time.Duration(n)
+}
+
+// Merge adds all latencies recorded by the other histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		counts := make([]uint64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded latencies.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Min returns the smallest recorded latency.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the largest recorded latency.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the mean of the recorded latencies.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the latency below or at which the specified percentage
+// (0 to 100) of the recorded latencies are.
+func (h *Histogram) Percentile(percent float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	// rank of the latency, starting at 1
+	rank := uint64(math.Ceil(percent / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var n uint64
+	for idx, count := range h.counts {
+		n += count
+		if n >= rank {
+			// report the upper bound of the bucket, limited to the
+			// recorded range
+			latency := time.Duration(histogramUpperBound(idx))
+			if latency > h.max {
+				latency = h.max
+			}
+			if latency < h.min {
+				latency = h.min
+			}
+			return latency
+		}
+	}
+	return h.max
+}
+
+// histogramIndex returns the bucket index of a value. Values below the number
+// of sub-buckets have buckets of their own.
+func histogramIndex(value uint64) int {
+	if value < histogramSubBuckets {
+		return int(value)
+	}
+	shift := uint(bits.Len64(value)) - histogramSubBits - 1
+	sub := (value >> shift) - histogramSubBuckets
+	return int(shift+1)*histogramSubBuckets + int(sub)
+}
+
+// histogramUpperBound returns the largest value of a bucket.
+func histogramUpperBound(idx int) uint64 {
+	if idx < histogramSubBuckets {
+		return uint64(idx)
+	}
+	shift := uint(idx/histogramSubBuckets) - 1
+	sub := uint64(idx%histogramSubBuckets) + histogramSubBuckets
+	return (sub+1)<<shift - 1
+}
@@ -0,0 +1,452 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tool benchmarks PCIe BAR (MMIO) accesses:
+//
+//   read-latency:     round trip latency of non-posted register reads
+//   posted-write:     rate of posted register writes. Writes are issued in
+//                     batches, each followed by a read that flushes them
+//   read-after-write: latency of a register write followed by a read
+//   wc-write:         throughput of block writes through a write-combining
+//                     mapping. Each block is followed by a flushing read
+//
+// Register tests are run for all selected access widths, the write-combining
+// test for all selected block sizes. The register tests write to the BAR, so
+// the address must point to a register that can be written safely (e.g. a
+// scratch register). The write-combining test overwrites a whole memory region
+// of the BAR, which must be specified explicitly. Results are reported in the
+// formats of pcie_dma_benchmark. The measured latencies include the overhead
+// of the gopcie BAR accessors (checking that the BAR is open and the access in
+// range), which is a few nanoseconds per access.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"github.com/aoeldemann/gopcie/utilities/internal/benchreport"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tests lists all tests in the order they are run.
+var tests = []string{"read-latency", "posted-write", "read-after-write",
+	"wc-write"}
+
+// defaultTests lists the tests run by default. The write-combining test
+// requires a memory region to be specified and is only run on request.
+var defaultTests = tests[:3]
+
+// params holds the benchmark parameters shared by all tests.
+type params struct {
+	addr     uint32
+	value    uint64
+	wcAddr   uint32
+	wcSize   uint64
+	duration time.Duration
+	count    uint64
+	batch    int
+}
+
+// sink receives the values read from the BAR, so that reads are not optimized
+// away.
+var sink uint64
+
+func main() {
+	// read command line arguments
+	var devAddr, functionIdStr, vendorIdStr, deviceIdStr, barIdStr string
+	var addrStr, valueStr, testsStr, widthsStr, blockSizesStr string
+	var wcAddrStr, wcSizeStr string
+	var format, outFilename string
+	var p params
+	flag.StringVar(&devAddr, "pci-addr", "",
+		"device PCI address, e.g. 0000:01:00.0 (alternative to IDs)")
+	flag.StringVar(&functionIdStr, "functionId", "", "device function ID")
+	flag.StringVar(&vendorIdStr, "vendorId", "", "device vendor ID")
+	flag.StringVar(&deviceIdStr, "deviceId", "", "device ID")
+	flag.StringVar(&barIdStr, "barId", "", "device BAR ID")
+	flag.StringVar(&addrStr, "addr", "",
+		"address of a register that can be written safely")
+	flag.StringVar(&valueStr, "value", "0", "value written to the register")
+	flag.StringVar(&testsStr, "tests", strings.Join(defaultTests, ","),
+		"comma-separated list of tests: "+strings.Join(tests, ", "))
+	flag.StringVar(&widthsStr, "widths", "8,16,32,64",
+		"comma-separated list of access widths in bits")
+	flag.StringVar(&blockSizesStr, "block-sizes", "40-1000",
+		"comma-separated list of block sizes of the write-combining test; "+
+			"min-max sweeps powers of two")
+	flag.StringVar(&wcAddrStr, "wc-addr", "", "address of the memory "+
+		"region overwritten by the write-combining test")
+	flag.StringVar(&wcSizeStr, "wc-size", "", "size of the memory region "+
+		"overwritten by the write-combining test")
+	flag.DurationVar(&p.duration, "duration", time.Second,
+		"duration per test and access width (0: unlimited)")
+	flag.Uint64Var(&p.count, "count", 0,
+		"number of accesses per test and access width (0: unlimited)")
+	flag.IntVar(&p.batch, "batch", 256,
+		"number of writes per batch of the posted write test")
+	flag.StringVar(&format, "format", "text", "output format: "+
+		strings.Join(benchreport.Formats, ", "))
+	flag.StringVar(&outFilename, "o", "", "output filename (default: stdout)")
+	flag.Parse()
+
+	// make sure parameters are set
+	if (len(devAddr) == 0 && (len(functionIdStr) == 0 ||
+		len(vendorIdStr) == 0 || len(deviceIdStr) == 0)) ||
+		len(barIdStr) == 0 || len(addrStr) == 0 {
+		flag.Usage()
+		return
+	}
+	if p.duration < 0 || (p.duration == 0 && p.count == 0) || p.batch < 1 {
+		panic("invalid duration, count or batch size")
+	}
+
+	// convert hex string values to int
+	barId, err := gopcie.HexStringToInt(barIdStr)
+	if err != nil {
+		panic("invalid BAR ID")
+	}
+	addr, err := gopcie.HexStringToInt(addrStr)
+	if err != nil || addr > 0xffffffff {
+		panic("invalid address")
+	}
+	p.addr = uint32(addr)
+	if p.addr%4 != 0 {
+		panic("address not 32 bit aligned")
+	}
+	p.value, err = gopcie.HexStringToInt(valueStr)
+	if err != nil {
+		panic("invalid value")
+	}
+
+	// parse tests, widths and block sizes
+	selectedTests := strings.Split(testsStr, ",")
+	for _, test := range selectedTests {
+		if !isTest(test) {
+			panic("unknown test " + test)
+		}
+		if test == "wc-write" {
+			// the write-combining test overwrites the region, so it must be
+			// specified explicitly
+			if len(wcAddrStr) == 0 || len(wcSizeStr) == 0 {
+				panic("wc-write test requires -wc-addr and -wc-size")
+			}
+			wcAddr, err := gopcie.HexStringToInt(wcAddrStr)
+			if err != nil || wcAddr > 0xffffffff || wcAddr%8 != 0 {
+				panic("invalid write-combining region address")
+			}
+			p.wcAddr = uint32(wcAddr)
+			p.wcSize, err = gopcie.HexStringToInt(wcSizeStr)
+			if err != nil || p.wcSize < 4 {
+				panic("invalid write-combining region size")
+			}
+		}
+	}
+	var widths []int
+	accessSize := 4 // the flushing reads are 32 bit wide
+	for _, widthStr := range strings.Split(widthsStr, ",") {
+		width, err := strconv.Atoi(widthStr)
+		if err != nil || (width != 8 && width != 16 && width != 32 &&
+			width != 64) {
+			panic("invalid access width " + widthStr)
+		}
+		if p.addr%uint32(width/8) != 0 {
+			panic("address not aligned to access width " + widthStr)
+		}
+		widths = append(widths, width)
+		if width/8 > accessSize {
+			accessSize = width / 8
+		}
+	}
+	blockSizes, err := benchreport.ParseSizes(blockSizesStr)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// find the device in sysfs
+	if len(devAddr) == 0 {
+		functionId, err := gopcie.HexStringToInt(functionIdStr)
+		if err != nil {
+			panic("invalid device function ID")
+		}
+		vendorId, err := gopcie.HexStringToInt(vendorIdStr)
+		if err != nil {
+			panic("invalid device vendor ID")
+		}
+		deviceId, err := gopcie.HexStringToInt(deviceIdStr)
+		if err != nil {
+			panic("invalid device ID")
+		}
+		devAddr, err = gopcie.PCIeDeviceFind(uint(functionId),
+			uint(vendorId), uint(deviceId))
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+
+	// open pcie bar
+	bar, err := gopcie.PCIeBAROpenAddr(devAddr, uint(barId))
+	if err != nil {
+		panic(err.Error())
+	}
+	defer bar.Close()
+	if uint64(p.addr)+uint64(accessSize) > uint64(bar.Size()) {
+		panic("address out of range")
+	}
+
+	// open output
+	var out io.Writer = os.Stdout
+	if len(outFilename) > 0 {
+		file, err := os.Create(outFilename)
+		if err != nil {
+			panic("could not create output file")
+		}
+		defer file.Close()
+		out = file
+	}
+	reporter, err := benchreport.NewReporter(out, format)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	fmt.Fprintf(os.Stderr, "note: latencies include the overhead of the "+
+		"BAR accessors (open and range checks)\n")
+
+	// stop benchmark on SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// run tests. if a BAR access fails, the results collected so far are
+	// reported
+	var errAccess error
+	for _, test := range selectedTests {
+		var results []benchreport.Result
+		if test == "wc-write" {
+			results, errAccess = runWCWrite(ctx, devAddr, uint(barId), bar,
+				blockSizes, p)
+		} else {
+			for _, width := range widths {
+				var result benchreport.Result
+				result, errAccess = runRegisterTest(ctx, test, bar, width, p)
+				results = append(results, result)
+				if ctx.Err() != nil || errAccess != nil {
+					break
+				}
+			}
+		}
+
+		for _, result := range results {
+			if err := reporter.Report(result); err != nil {
+				panic(err.Error())
+			}
+		}
+		if errAccess != nil {
+			break
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "interrupted\n")
+			break
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		panic(err.Error())
+	}
+	if errAccess != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", errAccess.Error())
+		os.Exit(1)
+	}
+}
+
+// isTest returns true if the name is one of the tests.
+func isTest(name string) bool {
+	for _, test := range tests {
+		if name == test {
+			return true
+		}
+	}
+	return false
+}
+
+// registerAccessors returns functions reading and writing the register with
+// the specified access width.
+func registerAccessors(bar *gopcie.PCIeBAR, width int,
+	p params) (read func() error, write func() error) {
+	switch width {
+	case 8:
+		read = func() error {
+			data, err := bar.TryRead8(p.addr)
+			sink += uint64(data)
+			return err
+		}
+		write = func() error { return bar.TryWrite8(p.addr, uint8(p.value)) }
+	case 16:
+		read = func() error {
+			data, err := bar.TryRead16(p.addr)
+			sink += uint64(data)
+			return err
+		}
+		write = func() error { return bar.TryWrite16(p.addr, uint16(p.value)) }
+	case 32:
+		read = func() error {
+			data, err := bar.TryRead(p.addr)
+			sink += uint64(data)
+			return err
+		}
+		write = func() error { return bar.TryWrite(p.addr, uint32(p.value)) }
+	default:
+		read = func() error {
+			data, err := bar.TryRead64(p.addr)
+			sink += data
+			return err
+		}
+		write = func() error { return bar.TryWrite64(p.addr, p.value) }
+	}
+	return read, write
+}
+
+// runRegisterTest runs a register test with the specified access width.
+func runRegisterTest(ctx context.Context, test string, bar *gopcie.PCIeBAR,
+	width int, p params) (benchreport.Result, error) {
+	read, write := registerAccessors(bar, width, p)
+
+	switch test {
+	case "read-latency":
+		return run(ctx, test, "read", width/8, 1, p, read)
+	case "posted-write":
+		// the flushing read makes sure the writes have reached the device
+		// before the batch is timed
+		return run(ctx, test, "write", width/8, p.batch, p, func() error {
+			for i := 0; i < p.batch; i++ {
+				if err := write(); err != nil {
+					return err
+				}
+			}
+			return read()
+		})
+	}
+	return run(ctx, test, "write+read", width/8, 1, p, func() error {
+		if err := write(); err != nil {
+			return err
+		}
+		return read()
+	})
+}
+
+// runWCWrite runs the write-combining block write test for all block sizes.
+// The flushing reads are issued through the regular mapping of the BAR.
+func runWCWrite(ctx context.Context, devAddr string, barId uint,
+	bar *gopcie.PCIeBAR, blockSizes []int,
+	p params) ([]benchreport.Result, error) {
+	wcBar, err := gopcie.PCIeBAROpenAddrWC(devAddr, barId)
+	if errors.Is(err, gopcie.ErrUnsupported) {
+		fmt.Fprintf(os.Stderr, "warning: skipping wc-write, BAR is not "+
+			"prefetchable\n")
+		return nil, nil
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+	defer wcBar.Close()
+
+	if uint64(p.wcAddr)+p.wcSize > uint64(wcBar.Size()) {
+		panic("write-combining region exceeds BAR")
+	}
+
+	var results []benchreport.Result
+	for _, blockSize := range blockSizes {
+		if uint64(blockSize) > p.wcSize || blockSize%4 != 0 {
+			fmt.Fprintf(os.Stderr, "warning: skipping block size 0x%x, "+
+				"exceeds write-combining region or not a multiple of 4\n",
+				blockSize)
+			continue
+		}
+		block := make([]byte, blockSize)
+		result, err := run(ctx, "wc-write", "write", blockSize, 1, p,
+			func() error {
+				if err := wcBar.TryWriteBlock(p.wcAddr, block); err != nil {
+					return err
+				}
+				data, err := bar.TryRead(p.wcAddr)
+				sink += uint64(data)
+				return err
+			})
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// run times the access function until the duration expired, the number of
+// accesses has been performed, the context is done or an access fails. Each
+// call of the access function performs the specified number of accesses of the
+// specified size. The error of a failed access is returned along with the
+// result of the accesses performed before.
+func run(ctx context.Context, test, op string, size, accessesPerCall int,
+	p params, access func() error) (benchreport.Result, error) {
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.duration > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, p.duration)
+	}
+	defer cancel()
+
+	var latencies benchreport.Histogram
+	var err error
+	startTime := time.Now()
+	for runCtx.Err() == nil &&
+		(p.count == 0 || latencies.Count() < p.count) {
+		callStartTime := time.Now()
+		if err = access(); err != nil {
+			break
+		}
+		latency := time.Since(callStartTime)
+		latencies.RecordN(latency/time.Duration(accessesPerCall),
+			uint64(accessesPerCall))
+	}
+	duration := time.Since(startTime)
+
+	result := benchreport.NewResult(test, op, size, 1,
+		latencies.Count()*uint64(size), 0, duration, &latencies)
+	result.Interrupted = ctx.Err() != nil || err != nil
+	return result, err
+}
@@ -0,0 +1,379 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tool benchmarks PCIe DMA read, write and bidirectional throughput and
+// latency. A list or sweep of transfer sizes is benchmarked for a fixed
+// duration or number of transfers each, with multiple concurrent workers per
+// direction. Results are reported as text, CSV or JSON. On SIGINT, the running
+// benchmark is stopped and the results collected so far are reported.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"github.com/aoeldemann/gopcie/utilities/internal/benchreport"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// direction holds the devices and buffers of a transfer direction.
+type direction struct {
+	op      string
+	devs    []*gopcie.PCIeDMA
+	bufs    [][]byte // one buffer per worker
+	dmaBufs []*gopcie.PCIeDMABuffer
+
+	// buffers taken from the devices' buffer pools
+	pools      []*gopcie.PCIeDMABufferPool
+	pooledBufs []*gopcie.PCIeDMABuffer
+}
+
+// params holds the benchmark parameters shared by all transfer sizes.
+type params struct {
+	addr      uint64
+	addrRange uint64
+	workers   int
+	duration  time.Duration
+	count     int64
+	numa      bool
+}
+
+// worker holds the statistics of a worker.
+type worker struct {
+	latencies benchreport.Histogram
+	bytes     uint64
+	errors    uint64
+}
+
+// numaWarning and pinWarning make sure the warnings about failed buffer
+// allocation and cpu pinning on the local numa node are only printed once.
+var numaWarning, pinWarning sync.Once
+
+func main() {
+	// read command line arguments
+	var mode, writeDevices, readDevices, addrStr, rangeStr, sizesStr string
+	var format, outFilename string
+	var p params
+	flag.StringVar(&mode, "mode", "write", "benchmark mode: write, read or "+
+		"bidir")
+	flag.StringVar(&writeDevices, "write-device", "",
+		"comma-separated list of write devices (channels)")
+	flag.StringVar(&readDevices, "read-device", "",
+		"comma-separated list of read devices (channels)")
+	flag.StringVar(&addrStr, "addr", "0", "start address")
+	flag.StringVar(&rangeStr, "range", "",
+		"size of the address range cycled through (default: size * workers)")
+	flag.StringVar(&sizesStr, "sizes", "100000",
+		"comma-separated list of transfer sizes; min-max sweeps powers of two")
+	flag.DurationVar(&p.duration, "duration", 5*time.Second,
+		"duration per transfer size (0: unlimited)")
+	flag.Int64Var(&p.count, "count", 0,
+		"number of transfers per transfer size and direction (0: unlimited)")
+	flag.IntVar(&p.workers, "workers", 1,
+		"number of concurrent workers per direction")
+	flag.BoolVar(&p.numa, "numa", false,
+		"allocate buffers and run on the device's local NUMA node")
+	flag.StringVar(&format, "format", "text", "output format: "+
+		strings.Join(benchreport.Formats, ", "))
+	flag.StringVar(&outFilename, "o", "", "output filename (default: stdout)")
+	flag.Parse()
+
+	// make sure parameters are set
+	doWrite := mode == "write" || mode == "bidir"
+	doRead := mode == "read" || mode == "bidir"
+	if (!doWrite && !doRead) || (doWrite && len(writeDevices) == 0) ||
+		(doRead && len(readDevices) == 0) {
+		flag.Usage()
+		return
+	}
+	if p.workers < 1 || p.duration < 0 || p.count < 0 ||
+		(p.duration == 0 && p.count == 0) {
+		panic("invalid workers, duration or count")
+	}
+
+	// convert hex addr string to int
+	var err error
+	p.addr, err = gopcie.HexStringToInt(addrStr)
+	if err != nil {
+		panic("invalid address")
+	}
+
+	// convert hex range string to int
+	if len(rangeStr) > 0 {
+		p.addrRange, err = gopcie.HexStringToInt(rangeStr)
+		if err != nil || p.addrRange == 0 {
+			panic("invalid range")
+		}
+	}
+
+	// parse transfer sizes
+	sizes, err := benchreport.ParseSizes(sizesStr)
+	if err != nil {
+		panic(err.Error())
+	}
+	maxSize := 0
+	for _, size := range sizes {
+		if p.addrRange > 0 && uint64(size) > p.addrRange {
+			panic("transfer size exceeds range")
+		}
+		if size > maxSize {
+			maxSize = size
+		}
+	}
+
+	// open devices and allocate buffers
+	var dirs []*direction
+	if doWrite {
+		dir, err := openDirection("write", writeDevices,
+			gopcie.PCIE_ACCESS_WRITE, p, maxSize)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer dir.close()
+		dirs = append(dirs, dir)
+	}
+	if doRead {
+		dir, err := openDirection("read", readDevices,
+			gopcie.PCIE_ACCESS_READ, p, maxSize)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer dir.close()
+		dirs = append(dirs, dir)
+	}
+
+	// open output
+	var out io.Writer = os.Stdout
+	if len(outFilename) > 0 {
+		file, err := os.Create(outFilename)
+		if err != nil {
+			panic("could not create output file")
+		}
+		defer file.Close()
+		out = file
+	}
+	reporter, err := benchreport.NewReporter(out, format)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// stop benchmark on SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// benchmark each transfer size
+	for _, size := range sizes {
+		results := runSize(ctx, mode, dirs, p, size)
+		for _, result := range results {
+			if err := reporter.Report(result); err != nil {
+				panic(err.Error())
+			}
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "interrupted\n")
+			break
+		}
+	}
+	if err := reporter.Close(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// openDirection opens the devices of a transfer direction and allocates a
+// buffer for each worker.
+func openDirection(op, devNames string, accessMode int, p params,
+	bufSize int) (*direction, error) {
+	dir := &direction{op: op}
+	for _, devName := range strings.Split(devNames, ",") {
+		dev, err := gopcie.PCIeDMAOpen(devName, accessMode)
+		if err != nil {
+			dir.close()
+			return nil, err
+		}
+		dir.devs = append(dir.devs, dev)
+	}
+
+	for i := 0; i < p.workers; i++ {
+		dev := dir.devs[i%len(dir.devs)]
+		var buf []byte
+		if p.numa {
+			dmaBuf, err := dev.BufferAllocLocal(uint64(bufSize), 0)
+			if err != nil {
+				numaWarning.Do(func() {
+					fmt.Fprintf(os.Stderr, "warning: could not allocate "+
+						"buffer on local numa node: %s\n", err.Error())
+				})
+			} else {
+				dir.dmaBufs = append(dir.dmaBufs, dmaBuf)
+				buf = dmaBuf.Bytes()[:bufSize]
+			}
+		}
+		if buf == nil {
+			pool, err := dev.BufferPool()
+			if err != nil {
+				dir.close()
+				return nil, err
+			}
+			dmaBuf, err := pool.Get(uint64(bufSize))
+			if err != nil {
+				dir.close()
+				return nil, err
+			}
+			dir.pools = append(dir.pools, pool)
+			dir.pooledBufs = append(dir.pooledBufs, dmaBuf)
+			buf = dmaBuf.Bytes()[:bufSize]
+		}
+		dir.bufs = append(dir.bufs, buf)
+	}
+	return dir, nil
+}
+
+// close frees the buffers and closes the devices of the direction.
+func (dir *direction) close() {
+	for _, dmaBuf := range dir.dmaBufs {
+		dmaBuf.Close()
+	}
+	for i, dmaBuf := range dir.pooledBufs {
+		dir.pools[i].Put(dmaBuf)
+	}
+	for _, dev := range dir.devs {
+		dev.Close()
+	}
+}
+
+// runSize benchmarks a transfer size in all directions concurrently and
+// returns one result per direction.
+func runSize(ctx context.Context, mode string, dirs []*direction, p params,
+	size int) []benchreport.Result {
+	// the deadline is shared by all directions
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.duration > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, p.duration)
+	}
+	defer cancel()
+
+	// each worker cycles through its own part of the address range in steps
+	// of the transfer size
+	addrRange := p.addrRange
+	if addrRange == 0 {
+		addrRange = uint64(size) * uint64(p.workers)
+	}
+	nSlots := addrRange / uint64(size)
+
+	workers := make([][]worker, len(dirs))
+	var wg sync.WaitGroup
+	startTime := time.Now()
+	for i, dir := range dirs {
+		workers[i] = make([]worker, p.workers)
+		var issued int64
+		for w := range workers[i] {
+			wg.Add(1)
+			go func(dir *direction, w int, stats *worker, issued *int64) {
+				defer wg.Done()
+				dev := dir.devs[w%len(dir.devs)]
+				if p.numa {
+					if err := dev.PinThreadToLocalCPUs(); err != nil {
+						pinWarning.Do(func() {
+							fmt.Fprintf(os.Stderr, "warning: could not pin "+
+								"to local cpus: %s\n", err.Error())
+						})
+					}
+				}
+				runWorker(runCtx, dir, dev, dir.bufs[w][:size], p, w, nSlots,
+					issued, stats)
+			}(dir, w, &workers[i][w], &issued)
+		}
+	}
+	wg.Wait()
+	duration := time.Since(startTime)
+
+	// merge statistics of all workers
+	var results []benchreport.Result
+	for i, dir := range dirs {
+		var latencies benchreport.Histogram
+		var bytes, errors uint64
+		for w := range workers[i] {
+			latencies.Merge(&workers[i][w].latencies)
+			bytes += workers[i][w].bytes
+			errors += workers[i][w].errors
+		}
+		result := benchreport.NewResult(mode, dir.op, size, p.workers, bytes,
+			errors, duration, &latencies)
+		result.Interrupted = ctx.Err() != nil
+		results = append(results, result)
+	}
+	return results
+}
+
+// runWorker performs transfers until the context is done, the number of
+// transfers has been issued or a transfer fails.
+func runWorker(ctx context.Context, dir *direction, dev *gopcie.PCIeDMA,
+	buf []byte, p params, w int, nSlots uint64, issued *int64,
+	stats *worker) {
+	transfer := dev.Write
+	if dir.op == "read" {
+		transfer = dev.Read
+	}
+
+	// spread workers over the address range, if it is large enough
+	slot := uint64(w) % nSlots
+	step := uint64(p.workers)
+	if step > nSlots {
+		step = 1
+	}
+
+	for ctx.Err() == nil {
+		if p.count > 0 && atomic.AddInt64(issued, 1) > p.count {
+			return
+		}
+
+		addr := p.addr + slot*uint64(len(buf))
+		startTime := time.Now()
+		err := transfer(addr, buf)
+		stats.latencies.Record(time.Since(startTime))
+		if err != nil {
+			stats.errors++
+			fmt.Fprintf(os.Stderr, "%s: %s\n", dir.op, err.Error())
+			return
+		}
+		stats.bytes += uint64(len(buf))
+		slot = (slot + step) % nSlots
+	}
+}
@@ -25,7 +25,7 @@
 //   - Andreas Oeldemann <andreas.oeldemann@tum.de>
 //
 // Date Created:        June 9th 2017
-// Date Last Modified:  July 4th 2017
+// Date Last Modified:  October 19th 2026
 //
 // Description:
 //
@@ -37,6 +37,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/aoeldemann/gopcie"
 	"os"
 )
@@ -45,10 +46,13 @@ func main() {
 	// read command line arguments
 	var addrStr, sizeStr string
 	var filename, device string
+	var numa bool
 	flag.StringVar(&addrStr, "addr", "", "address")
 	flag.StringVar(&sizeStr, "size", "", "size")
 	flag.StringVar(&filename, "file", "", "target filename")
 	flag.StringVar(&device, "device", "", "device")
+	flag.BoolVar(&numa, "numa", false,
+		"allocate buffer and run on the device's local NUMA node")
 	flag.Parse()
 
 	// make sure parameters are set
@@ -84,8 +88,33 @@ func main() {
 	}
 	defer file.Close()
 
+	// create buffer for read data
+	var data []byte
+	if numa {
+		// run on the cpus local to the device and allocate the buffer on its
+		// numa node
+		var release func()
+		data, release, err = gopcie.PinToDeviceNode(dev, size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"warning: could not run on local numa node: %s\n", err.Error())
+		}
+		defer release()
+	} else {
+		// take the buffer from the device's buffer pool
+		pool, err := dev.BufferPool()
+		if err != nil {
+			panic(err.Error())
+		}
+		buf, err := pool.Get(size)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer pool.Put(buf)
+		data = buf.Bytes()[:size]
+	}
+
 	// read data from pcie device
-	data := make([]byte, size)
 	dev.Read(addr, data)
 
 	// write data to output file
@@ -0,0 +1,133 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Command-line utility to compare the memory of a PCIExpress device against a
+// file via DMA transfers.
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"io"
+	"os"
+)
+
+func main() {
+	// read command line arguments
+	var addrStr, chunkSizeStr string
+	var filename, device string
+	var maxReported int
+	flag.StringVar(&addrStr, "addr", "", "address")
+	flag.StringVar(&filename, "file", "", "reference filename")
+	flag.StringVar(&device, "device", "", "device")
+	flag.StringVar(&chunkSizeStr, "chunk", "100000",
+		"size of the chunks compared at once")
+	flag.IntVar(&maxReported, "max-reported", 16,
+		"maximum number of mismatches printed")
+	flag.Parse()
+
+	// make sure parameters are set
+	if len(addrStr) == 0 || len(filename) == 0 || len(device) == 0 {
+		flag.Usage()
+		return
+	}
+
+	// convert hex addr string to int
+	addr, err := gopcie.HexStringToInt(addrStr)
+	if err != nil {
+		panic("invalid address")
+	}
+
+	// convert hex chunk size string to int
+	chunkSize, err := gopcie.HexStringToInt(chunkSizeStr)
+	if err != nil || chunkSize == 0 {
+		panic("invalid chunk size")
+	}
+
+	// create and open pcie device
+	dev, err := gopcie.PCIeDMAOpen(device, gopcie.PCIE_ACCESS_READ)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer dev.Close()
+
+	// open reference file
+	file, err := os.Open(filename)
+	if err != nil {
+		panic("could not open reference file")
+	}
+	defer file.Close()
+
+	// compare card memory chunk by chunk
+	var size, nMismatches uint64
+	var mismatches []gopcie.PCIeMismatch
+	data := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(file, data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			panic("could not read reference file")
+		}
+
+		err = dev.Verify(addr+size, data[:n])
+		var mismatchErr *gopcie.PCIeMismatchError
+		if errors.As(err, &mismatchErr) {
+			nMismatches += uint64(mismatchErr.Count)
+			mismatches = append(mismatches, mismatchErr.Mismatches...)
+		} else if err != nil {
+			panic(err.Error())
+		}
+		size += uint64(n)
+	}
+
+	// print summary
+	if nMismatches == 0 {
+		fmt.Printf("0x%x bytes at 0x%x match\n", size, addr)
+		return
+	}
+	fmt.Printf("%d of 0x%x bytes at 0x%x mismatch\n", nMismatches, size,
+		addr)
+	for i, mismatch := range mismatches {
+		if i == maxReported {
+			fmt.Printf("...\n")
+			break
+		}
+		fmt.Printf("0x%x: expected 0x%02x, read 0x%02x\n", mismatch.Addr,
+			mismatch.Expected, mismatch.Actual)
+	}
+	os.Exit(1)
+}
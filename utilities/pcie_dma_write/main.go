@@ -25,7 +25,7 @@
 //   - Andreas Oeldemann <andreas.oeldemann@tum.de>
 //
 // Date Created:        June 9th 2017
-// Date Last Modified:  July 4th 2017
+// Date Last Modified:  October 19th 2026
 //
 // Description:
 //
@@ -37,18 +37,26 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/aoeldemann/gopcie"
-	"io/ioutil"
+	"io"
 	"os"
 )
 
 func main() {
 	// read command line arguments
 	var addrStr string
-	var filename, device string
+	var filename, device, readDevice string
+	var numa, verify bool
 	flag.StringVar(&addrStr, "addr", "", "addr")
 	flag.StringVar(&filename, "file", "", "source filename")
 	flag.StringVar(&device, "device", "", "device")
+	flag.BoolVar(&numa, "numa", false,
+		"allocate buffer and run on the device's local NUMA node")
+	flag.BoolVar(&verify, "verify", false,
+		"read written data back and compare it")
+	flag.StringVar(&readDevice, "read-device", "",
+		"device the written data is read back from (default: device)")
 	flag.Parse()
 
 	// make sure parameters are set
@@ -64,17 +72,32 @@ func main() {
 	}
 
 	// create and open pcie device
-	dev, err := gopcie.PCIeDMAOpen(device, gopcie.PCIE_ACCESS_WRITE)
+	accessMode := gopcie.PCIE_ACCESS_WRITE
+	if verify && len(readDevice) == 0 {
+		accessMode |= gopcie.PCIE_ACCESS_READ
+	}
+	dev, err := gopcie.PCIeDMAOpen(device, accessMode)
 	if err != nil {
 		panic(err.Error())
 	}
 	defer dev.Close()
 
+	// open device the written data is read back from
+	readDev := dev
+	if verify && len(readDevice) > 0 {
+		readDev, err = gopcie.PCIeDMAOpen(readDevice, gopcie.PCIE_ACCESS_READ)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer readDev.Close()
+	}
+
 	// open input file
 	file, err := os.Open(filename)
 	if err != nil {
 		panic("could not open input file")
 	}
+	defer file.Close()
 
 	// get input file size
 	fileInfo, err := file.Stat()
@@ -83,15 +106,47 @@ func main() {
 	}
 	fileSize := fileInfo.Size()
 
-	// close file
-	file.Close()
+	// create buffer for write data
+	var data []byte
+	switch {
+	case fileSize == 0:
+		// nothing to write. the pool does not hand out empty buffers
+	case numa:
+		// run on the cpus local to the device and allocate the buffer on its
+		// numa node
+		var release func()
+		data, release, err = gopcie.PinToDeviceNode(dev, uint64(fileSize))
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"warning: could not run on local numa node: %s\n", err.Error())
+		}
+		defer release()
+	default:
+		// take the buffer from the device's buffer pool
+		pool, err := dev.BufferPool()
+		if err != nil {
+			panic(err.Error())
+		}
+		buf, err := pool.Get(uint64(fileSize))
+		if err != nil {
+			panic(err.Error())
+		}
+		defer pool.Put(buf)
+		data = buf.Bytes()[:fileSize]
+	}
 
 	// read input file
-	data, err := ioutil.ReadFile(filename)
-	if err != nil || len(data) != int(fileSize) {
+	_, err = io.ReadFull(file, data)
+	if err != nil {
 		panic("could not read input file")
 	}
 
 	// write to pcie dev
-	dev.Write(addr, data)
+	if verify {
+		if err := dev.WriteVerifyVia(readDev, addr, data); err != nil {
+			panic(err.Error())
+		}
+	} else {
+		dev.Write(addr, data)
+	}
 }
@@ -25,7 +25,7 @@
 //   - Andreas Oeldemann <andreas.oeldemann@tum.de>
 //
 // Date Created:        January 25th 2018
-// Date Last Modified:  January 25th 2018
+// Date Last Modified:  October 19th 2026
 //
 // Description:
 //
@@ -38,15 +38,26 @@ import (
 	"flag"
 	"fmt"
 	"github.com/aoeldemann/gopcie"
+	"os"
 	"time"
 )
 
 func main() {
 	// read command line arguments
-	var addrStr, sizeStr, device string
+	var addrStr, sizeStr, transferSizeStr, device string
+	var numa, uring bool
+	var uringEntries int
 	flag.StringVar(&addrStr, "addr", "", "addr")
 	flag.StringVar(&sizeStr, "size", "", "size")
+	flag.StringVar(&transferSizeStr, "transfer", "",
+		"size of a single transfer (default: size)")
 	flag.StringVar(&device, "device", "", "device")
+	flag.BoolVar(&numa, "numa", false,
+		"allocate buffer and run on the device's local NUMA node")
+	flag.BoolVar(&uring, "uring", false,
+		"submit transfers in batches via io_uring")
+	flag.IntVar(&uringEntries, "uring-entries", 64,
+		"number of io_uring submission queue entries")
 	flag.Parse()
 
 	// make sure parameters are set
@@ -67,6 +78,15 @@ func main() {
 		panic("invalid address")
 	}
 
+	// convert hex transfer size string to int
+	transferSize := size
+	if len(transferSizeStr) > 0 {
+		transferSize, err = gopcie.HexStringToInt(transferSizeStr)
+		if err != nil || transferSize == 0 {
+			panic("invalid transfer size")
+		}
+	}
+
 	// create and open pcie device
 	dev, err := gopcie.PCIeDMAOpen(device, gopcie.PCIE_ACCESS_WRITE)
 	if err != nil {
@@ -74,15 +94,86 @@ func main() {
 	}
 	defer dev.Close()
 
-	// create a byte slice containing write data (all zeros)
-	data := make([]byte, size)
+	// create a buffer containing write data (all zeros)
+	var data []byte
+	if numa {
+		// run on the cpus local to the device and allocate the buffer on its
+		// numa node
+		var release func()
+		data, release, err = gopcie.PinToDeviceNode(dev, size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"warning: could not run on local numa node: %s\n", err.Error())
+		}
+		defer release()
+	} else {
+		// take the buffer from the device's buffer pool
+		pool, err := dev.BufferPool()
+		if err != nil {
+			panic(err.Error())
+		}
+		buf, err := pool.Get(size)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer pool.Put(buf)
+		data = buf.Bytes()[:size]
+	}
+
+	// split data into transfers
+	var reqs []*gopcie.PCIeDMARequest
+	for offset := uint64(0); offset < size; offset += transferSize {
+		end := offset + transferSize
+		if end > size {
+			end = size
+		}
+		reqs = append(reqs, &gopcie.PCIeDMARequest{
+			Op:   gopcie.PCIE_ACCESS_WRITE,
+			Addr: addr + offset,
+			Data: data[offset:end],
+		})
+	}
+
+	// set up io_uring
+	var ring *gopcie.PCIeDMAUring
+	if uring {
+		ring, err = dev.UringOpen(uringEntries)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer ring.Close()
+		if !ring.UsesUring() {
+			fmt.Fprintf(os.Stderr,
+				"warning: io_uring not available, using pwrite\n")
+		}
+		if err := ring.RegisterBuffers([][]byte{data}); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not register buffer: %s\n",
+				err.Error())
+		}
+	}
 
 	for {
 		// record time before transfer
 		transferStartTime := time.Now()
 
 		// write to pcie dev
-		dev.Write(addr, data)
+		if ring != nil {
+			completions, err := ring.Transfer(reqs)
+			if err != nil {
+				panic(err.Error())
+			}
+			for _, completion := range completions {
+				if completion.Err != nil {
+					panic(completion.Err.Error())
+				}
+			}
+		} else {
+			for _, req := range reqs {
+				if err := dev.Write(req.Addr, req.Data); err != nil {
+					panic(err.Error())
+				}
+			}
+		}
 
 		// get duration since transfer start
 		transferDuration := time.Since(transferStartTime)
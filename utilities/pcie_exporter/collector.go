@@ -0,0 +1,551 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Collection of the exported metrics. Device presence, link status and AER
+// counters are read from sysfs and BAR registers are read at each scrape.
+// Counter registers are additionally polled in the background, so that
+// wraparounds between scrapes are accounted for. DMA transfer metrics are
+// fetched from the expvar endpoints of applications using gopcie.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dmaSourceTimeout is the timeout of fetching DMA transfer metrics from an
+// expvar endpoint.
+const dmaSourceTimeout = 5 * time.Second
+
+// exporterMetricNames are the names of the metrics exported for all devices.
+// Registers may not use them.
+var exporterMetricNames = map[string]bool{
+	"pcie_device_up":                  true,
+	"pcie_link_speed_gts":             true,
+	"pcie_link_width":                 true,
+	"pcie_link_max_speed_gts":         true,
+	"pcie_link_max_width":             true,
+	"pcie_aer_errors_total":           true,
+	"pcie_dma_source_up":              true,
+	"pcie_dma_operations_total":       true,
+	"pcie_dma_bytes_total":            true,
+	"pcie_dma_errors_total":           true,
+	"pcie_dma_short_transfers_total":  true,
+	"pcie_dma_latency_seconds":        true,
+	"pcie_dma_latency_seconds_bucket": true,
+	"pcie_dma_latency_seconds_sum":    true,
+	"pcie_dma_latency_seconds_count":  true,
+}
+
+// collector collects the metrics of all configured devices and DMA sources.
+type collector struct {
+	// the mutex protects the device state against concurrent scrapes and
+	// counter polls
+	mutex   sync.Mutex
+	cfg     *config
+	devices []*device
+	client  *http.Client
+	done    chan struct{}
+
+	// the source mutex serializes fetching the dma sources, which is done
+	// without holding the device mutex
+	sourceMutex sync.Mutex
+	sourceErrs  []string // last errors of fetching the dma sources
+}
+
+// device holds the state of a monitored device.
+type device struct {
+	cfg       *deviceConfig
+	present   bool
+	bars      map[uint]*gopcie.PCIeBAR
+	openErr   string
+	registers []*register
+}
+
+// register holds the state of an exported BAR register.
+type register struct {
+	cfg    *registerConfig
+	labels []label // configured labels, sorted by name
+
+	// counter state: last value read from the register and accumulated
+	// counter value
+	last  uint64
+	total uint64
+
+	// last error reading the register
+	readErr string
+}
+
+// newCollector creates a collector and starts polling counter registers.
+func newCollector(cfg *config) *collector {
+	c := &collector{
+		cfg:    cfg,
+		client: &http.Client{Timeout: dmaSourceTimeout},
+		done:   make(chan struct{}),
+
+		sourceErrs: make([]string, len(cfg.DMASources)),
+	}
+	for i := range cfg.Devices {
+		dev := &device{cfg: &cfg.Devices[i]}
+		for j := range dev.cfg.Registers {
+			reg := &register{cfg: &dev.cfg.Registers[j]}
+			for name, value := range reg.cfg.Labels {
+				reg.labels = append(reg.labels, label{name, value})
+			}
+			sort.Slice(reg.labels, func(a, b int) bool {
+				return reg.labels[a].name < reg.labels[b].name
+			})
+			dev.registers = append(dev.registers, reg)
+		}
+		c.devices = append(c.devices, dev)
+	}
+
+	go c.poll()
+	return c
+}
+
+// Close stops polling and closes all BARs.
+func (c *collector) Close() {
+	close(c.done)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, dev := range c.devices {
+		dev.closeBARs()
+	}
+}
+
+// poll periodically updates the counter registers.
+func (c *collector) poll() {
+	ticker := time.NewTicker(c.cfg.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mutex.Lock()
+		for _, dev := range c.devices {
+			if dev.update() {
+				dev.readCounters()
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// ServeHTTP serves the metrics in text exposition format.
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	set := newMetricSet()
+	c.collectDevices(set)
+	c.collectDMASources(set)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := set.write(w); err != nil {
+		log.Printf("write metrics: %v", err)
+	}
+}
+
+// collectDevices adds the metrics of all devices to the set.
+func (c *collector) collectDevices(set *metricSet) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, dev := range c.devices {
+		labels := []label{{"device", dev.cfg.Name}, {"addr", dev.cfg.Addr}}
+
+		dev.update()
+		set.add("pcie_device_up", "Whether the PCIExpress device is present.",
+			"gauge", boolToFloat(dev.present), labels...)
+		if !dev.present {
+			continue
+		}
+
+		// link status
+		link, err := gopcie.PCIeReadLinkStatus(dev.cfg.Addr)
+		if err == nil {
+			set.add("pcie_link_speed_gts",
+				"Negotiated link speed in GT/s (0 if unknown).", "gauge",
+				link.Speed, labels...)
+			set.add("pcie_link_width", "Negotiated link width in lanes.",
+				"gauge", float64(link.Width), labels...)
+			set.add("pcie_link_max_speed_gts",
+				"Maximum link speed in GT/s (0 if unknown).", "gauge",
+				link.MaxSpeed, labels...)
+			set.add("pcie_link_max_width", "Maximum link width in lanes.",
+				"gauge", float64(link.MaxWidth), labels...)
+		}
+
+		// aer counters. devices without aer support do not report them
+		aer, err := gopcie.PCIeReadAERCounters(dev.cfg.Addr)
+		if err == nil {
+			severities := []struct {
+				name     string
+				counters map[string]uint64
+			}{
+				{"correctable", aer.Correctable},
+				{"nonfatal", aer.NonFatal},
+				{"fatal", aer.Fatal},
+			}
+			for _, severity := range severities {
+				names := make([]string, 0, len(severity.counters))
+				for name := range severity.counters {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					set.add("pcie_aer_errors_total",
+						"Number of errors reported by Advanced Error "+
+							"Reporting.", "counter",
+						float64(severity.counters[name]),
+						withLabels(labels, label{"severity", severity.name},
+							label{"error", name})...)
+				}
+			}
+		}
+
+		// registers
+		if dev.bars == nil {
+			continue
+		}
+		dev.readCounters()
+		for _, reg := range dev.registers {
+			value := reg.total
+			if reg.cfg.Type == "gauge" {
+				var ok bool
+				if value, ok = dev.readRegister(reg); !ok {
+					continue
+				}
+			}
+			set.add(reg.cfg.Name, reg.cfg.Help, reg.cfg.Type, float64(value),
+				withLabels(labels, reg.labels...)...)
+		}
+	}
+}
+
+// update checks if the device is present and opens or closes its BARs
+// accordingly. It returns true if the device is present and its BARs are
+// open.
+func (dev *device) update() bool {
+	dev.present = gopcie.PCIeDevicePresent(dev.cfg.Addr)
+	if !dev.present {
+		dev.closeBARs()
+		return false
+	}
+	if dev.bars != nil {
+		return true
+	}
+
+	// open all BARs holding registers and make sure registers are within
+	// range
+	bars := make(map[uint]*gopcie.PCIeBAR)
+	err := func() error {
+		for _, reg := range dev.registers {
+			bar, ok := bars[reg.cfg.BAR]
+			if !ok {
+				var err error
+				bar, err = gopcie.PCIeBAROpenAddr(dev.cfg.Addr, reg.cfg.BAR)
+				if err != nil {
+					return err
+				}
+				bars[reg.cfg.BAR] = bar
+			}
+			if uint64(reg.cfg.offset)+uint64(reg.cfg.Width/8) >
+				uint64(bar.Size()) {
+				return fmt.Errorf("register %s: offset 0x%x out of range",
+					reg.cfg.Name, reg.cfg.offset)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		for _, bar := range bars {
+			bar.Close()
+		}
+
+		// only log changing errors, since opening is retried at each scrape
+		if err.Error() != dev.openErr {
+			log.Printf("device %s: %v", dev.cfg.Name, err)
+			dev.openErr = err.Error()
+		}
+		return false
+	}
+	dev.openErr = ""
+	dev.bars = bars
+	return true
+}
+
+// closeBARs closes the BARs of the device. Counter registers are expected to
+// have been reset when the BARs are reopened (e.g. because the device has been
+// removed and rescanned), so their next value is added to the accumulated
+// counter value. Likewise, the first value read after starting the exporter is
+// taken as the initial counter value.
+func (dev *device) closeBARs() {
+	for _, bar := range dev.bars {
+		bar.Close()
+	}
+	dev.bars = nil
+	for _, reg := range dev.registers {
+		reg.last = 0
+	}
+}
+
+// readCounters updates the accumulated values of all counter registers. The
+// BARs must be open.
+func (dev *device) readCounters() {
+	for _, reg := range dev.registers {
+		if reg.cfg.Type != "counter" {
+			continue
+		}
+
+		// the difference to the last value modulo the register width is the
+		// number of counted events, provided that the register wrapped around
+		// at most once since the last read
+		value, ok := dev.readRegister(reg)
+		if !ok {
+			continue
+		}
+		delta := value - reg.last
+		if reg.cfg.Width == 32 {
+			delta &= math.MaxUint32
+		}
+		reg.total += delta
+		reg.last = value
+	}
+}
+
+// readRegister reads a register. It returns false if the register could not
+// be read, in which case the error is logged (only if it changed, since the
+// register is read at each scrape and poll).
+func (dev *device) readRegister(reg *register) (uint64, bool) {
+	value, err := dev.readRegisterValue(reg.cfg)
+	if err != nil {
+		if err.Error() != reg.readErr {
+			log.Printf("device %s: register %s: %v", dev.cfg.Name,
+				reg.cfg.Name, err)
+			reg.readErr = err.Error()
+		}
+		return 0, false
+	}
+	reg.readErr = ""
+	return value, true
+}
+
+// readRegisterValue reads the value of a register. 64 bit registers are read
+// as two 32 bit words. The upper word is read twice, so that a carry from the
+// lower word between the accesses is detected.
+func (dev *device) readRegisterValue(cfg *registerConfig) (uint64, error) {
+	bar := dev.bars[cfg.BAR]
+	if cfg.Width == 32 {
+		value, err := bar.TryRead(cfg.offset)
+		return uint64(value), err
+	}
+	for {
+		high, err := bar.TryRead(cfg.offset + 4)
+		if err != nil {
+			return 0, err
+		}
+		low, err := bar.TryRead(cfg.offset)
+		if err != nil {
+			return 0, err
+		}
+		highAgain, err := bar.TryRead(cfg.offset + 4)
+		if err != nil {
+			return 0, err
+		}
+		if highAgain == high {
+			return uint64(high)<<32 | uint64(low), nil
+		}
+	}
+}
+
+// collectDMASources fetches the DMA transfer metrics from all DMA sources and
+// adds them to the set.
+func (c *collector) collectDMASources(set *metricSet) {
+	c.sourceMutex.Lock()
+	defer c.sourceMutex.Unlock()
+
+	// fetch all sources concurrently
+	metrics := make([]map[string]gopcie.PCIeDMAMetrics, len(c.cfg.DMASources))
+	var wg sync.WaitGroup
+	for i, source := range c.cfg.DMASources {
+		wg.Add(1)
+		go func(i int, source dmaSourceConfig) {
+			defer wg.Done()
+			var err error
+			metrics[i], err = c.fetchDMAMetrics(source.URL)
+
+			// only log changing errors, since fetching is retried at each
+			// scrape
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != c.sourceErrs[i] && err != nil {
+				log.Printf("dma source %s: %v", source.Name, err)
+			}
+			c.sourceErrs[i] = errStr
+		}(i, source)
+	}
+	wg.Wait()
+
+	for i, source := range c.cfg.DMASources {
+		set.add("pcie_dma_source_up",
+			"Whether DMA transfer metrics could be fetched from the source.",
+			"gauge", boolToFloat(metrics[i] != nil),
+			label{"source", source.Name})
+	}
+
+	for i, source := range c.cfg.DMASources {
+		paths := make([]string, 0, len(metrics[i]))
+		for path := range metrics[i] {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			devMetrics := metrics[i][path]
+			ops := []struct {
+				name    string
+				metrics *gopcie.PCIeDMAOpMetrics
+			}{
+				{"read", &devMetrics.Read},
+				{"write", &devMetrics.Write},
+			}
+			for _, op := range ops {
+				labels := []label{{"source", source.Name}, {"path", path},
+					{"op", op.name}}
+				addDMAOpMetrics(set, op.metrics, labels)
+			}
+		}
+	}
+}
+
+// fetchDMAMetrics fetches the DMA transfer metrics published via expvar from
+// the url. Applications that have not enabled metrics for any device do not
+// publish the gopcie map, in which case no metrics are returned.
+func (c *collector) fetchDMAMetrics(url string) (
+	map[string]gopcie.PCIeDMAMetrics, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+
+	var vars struct {
+		Gopcie map[string]gopcie.PCIeDMAMetrics `json:"gopcie"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", url, err)
+	}
+	if vars.Gopcie == nil {
+		vars.Gopcie = make(map[string]gopcie.PCIeDMAMetrics)
+	}
+	return vars.Gopcie, nil
+}
+
+// addDMAOpMetrics adds the DMA transfer metrics of reads or writes to the set.
+// The bucket bounds of the latency histograms are taken from the metrics, so
+// sources using different bounds are exported correctly. Histograms without
+// consistent bounds (e.g. from sources using an older gopcie version) are
+// skipped.
+func addDMAOpMetrics(set *metricSet, metrics *gopcie.PCIeDMAOpMetrics,
+	labels []label) {
+	set.add("pcie_dma_operations_total", "Number of DMA transfers.",
+		"counter", float64(metrics.Operations), labels...)
+	set.add("pcie_dma_bytes_total", "Number of bytes transferred via DMA.",
+		"counter", float64(metrics.Bytes), labels...)
+	set.add("pcie_dma_errors_total", "Number of failed DMA transfers.",
+		"counter", float64(metrics.Errors), labels...)
+	set.add("pcie_dma_short_transfers_total",
+		"Number of short DMA transfers.", "counter",
+		float64(metrics.ShortTransfers), labels...)
+
+	family := set.family("pcie_dma_latency_seconds",
+		"Latency of DMA transfers per transfer size class.", "histogram")
+	for _, histogram := range metrics.Latency {
+		if len(histogram.Buckets) != len(histogram.Bounds)+1 {
+			continue
+		}
+
+		maxSize := "+Inf"
+		if histogram.MaxSize > 0 {
+			maxSize = strconv.Itoa(histogram.MaxSize)
+		}
+		histLabels := withLabels(labels, label{"max_size", maxSize})
+
+		// prometheus histogram buckets are cumulative
+		var count uint64
+		for i, n := range histogram.Buckets {
+			count += n
+			le := "+Inf"
+			if i < len(histogram.Bounds) {
+				le = formatValue(histogram.Bounds[i].Seconds())
+			}
+			family.add("_bucket", float64(count),
+				withLabels(histLabels, label{"le", le})...)
+		}
+		family.add("_sum", histogram.Sum.Seconds(), histLabels...)
+		family.add("_count", float64(histogram.Count), histLabels...)
+	}
+}
+
+// withLabels returns a copy of the labels with the additional labels appended.
+func withLabels(labels []label, additional ...label) []label {
+	result := make([]label, 0, len(labels)+len(additional))
+	result = append(result, labels...)
+	return append(result, additional...)
+}
+
+// boolToFloat returns 1 if b is true and 0 otherwise.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,214 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Configuration file of the Prometheus exporter. The JSON file lists the
+// monitored devices with the BAR registers exported for each of them and the
+// expvar endpoints of applications whose DMA transfer metrics are exported,
+// e.g.:
+//
+//   {
+//     "poll_interval": "1s",
+//     "devices": [
+//       {
+//         "name": "card0",
+//         "addr": "0000:01:00.0",
+//         "registers": [
+//           {
+//             "name": "card_rx_packets_total",
+//             "help": "Number of received packets.",
+//             "type": "counter",
+//             "bar": 0,
+//             "offset": "0x100",
+//             "width": 32,
+//             "labels": {"port": "0"}
+//           }
+//         ]
+//       }
+//     ],
+//     "dma_sources": [
+//       {"name": "app", "url": "http://localhost:8080/debug/vars"}
+//     ]
+//   }
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"os"
+	"regexp"
+	"time"
+)
+
+// defaultPollInterval is the default interval counter registers are polled
+// at.
+const defaultPollInterval = time.Second
+
+// metricNameRegexp matches valid Prometheus metric and label names.
+var metricNameRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// config is the exporter configuration.
+type config struct {
+	// PollInterval is the interval counter registers are polled at, so that
+	// wraparounds between scrapes are not missed. Defaults to 1 second.
+	PollInterval string `json:"poll_interval"`
+
+	Devices    []deviceConfig    `json:"devices"`
+	DMASources []dmaSourceConfig `json:"dma_sources"`
+
+	pollInterval time.Duration
+}
+
+// deviceConfig is the configuration of a monitored device.
+type deviceConfig struct {
+	Name      string           `json:"name"` // value of the device label
+	Addr      string           `json:"addr"` // pci address, e.g. 0000:01:00.0
+	Registers []registerConfig `json:"registers"`
+}
+
+// registerConfig is the configuration of an exported BAR register.
+type registerConfig struct {
+	Name   string            `json:"name"`   // metric name
+	Help   string            `json:"help"`   // metric help text
+	Type   string            `json:"type"`   // "gauge" or "counter"
+	BAR    uint              `json:"bar"`    // BAR ID
+	Offset string            `json:"offset"` // hex register offset
+	Width  int               `json:"width"`  // 32 (default) or 64 bits
+	Labels map[string]string `json:"labels"` // additional labels
+
+	offset uint32
+}
+
+// dmaSourceConfig is the configuration of an application publishing DMA
+// transfer metrics via expvar.
+type dmaSourceConfig struct {
+	Name string `json:"name"` // value of the source label
+	URL  string `json:"url"`  // url of the expvar endpoint
+}
+
+// readConfig reads and validates the configuration file.
+func readConfig(filename string) (*config, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cfg config
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filename, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &cfg, nil
+}
+
+// validate validates the configuration and sets default values.
+func (cfg *config) validate() error {
+	cfg.pollInterval = defaultPollInterval
+	if cfg.PollInterval != "" {
+		pollInterval, err := time.ParseDuration(cfg.PollInterval)
+		if err != nil || pollInterval <= 0 {
+			return fmt.Errorf("invalid poll interval %q", cfg.PollInterval)
+		}
+		cfg.pollInterval = pollInterval
+	}
+
+	// registers of the same metric must agree on type and help text
+	metricTypes := make(map[string]string)
+	metricHelps := make(map[string]string)
+
+	for i := range cfg.Devices {
+		dev := &cfg.Devices[i]
+		if dev.Addr == "" {
+			return fmt.Errorf("device %d: missing pci address", i)
+		}
+		if dev.Name == "" {
+			dev.Name = dev.Addr
+		}
+
+		for j := range dev.Registers {
+			reg := &dev.Registers[j]
+			if !metricNameRegexp.MatchString(reg.Name) ||
+				exporterMetricNames[reg.Name] {
+				return fmt.Errorf("device %s: invalid register name %q",
+					dev.Name, reg.Name)
+			}
+			if reg.Type != "gauge" && reg.Type != "counter" {
+				return fmt.Errorf("register %s: invalid type %q", reg.Name,
+					reg.Type)
+			}
+			if reg.Width == 0 {
+				reg.Width = 32
+			}
+			if reg.Width != 32 && reg.Width != 64 {
+				return fmt.Errorf("register %s: invalid width %d", reg.Name,
+					reg.Width)
+			}
+			offset, err := gopcie.HexStringToInt(reg.Offset)
+			if err != nil || offset%4 != 0 || offset > 0xffffffff {
+				return fmt.Errorf("register %s: invalid offset %q", reg.Name,
+					reg.Offset)
+			}
+			reg.offset = uint32(offset)
+			for name := range reg.Labels {
+				if !metricNameRegexp.MatchString(name) || name == "device" ||
+					name == "addr" {
+					return fmt.Errorf("register %s: invalid label %q",
+						reg.Name, name)
+				}
+			}
+
+			if typ, ok := metricTypes[reg.Name]; ok &&
+				(typ != reg.Type || metricHelps[reg.Name] != reg.Help) {
+				return fmt.Errorf("register %s: type and help differ from "+
+					"other registers of the same name", reg.Name)
+			}
+			metricTypes[reg.Name] = reg.Type
+			metricHelps[reg.Name] = reg.Help
+		}
+	}
+
+	for i, source := range cfg.DMASources {
+		if source.URL == "" {
+			return fmt.Errorf("dma source %d: missing url", i)
+		}
+		if source.Name == "" {
+			cfg.DMASources[i].Name = source.URL
+		}
+	}
+	return nil
+}
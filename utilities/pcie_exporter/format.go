@@ -0,0 +1,154 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Prometheus text exposition format. Samples are collected in metric families,
+// which are written in the order they were first added.
+//
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// label is a label of a sample.
+type label struct {
+	name  string
+	value string
+}
+
+// sample is a sample of a metric family.
+type sample struct {
+	suffix string // name suffix, e.g. "_bucket" for histograms
+	labels []label
+	value  float64
+}
+
+// metricFamily holds all samples of a metric.
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string // "gauge", "counter" or "histogram"
+	samples []sample
+}
+
+// metricSet holds the metric families of a scrape.
+type metricSet struct {
+	families []*metricFamily
+	byName   map[string]*metricFamily
+}
+
+// newMetricSet creates an empty metric set.
+func newMetricSet() *metricSet {
+	return &metricSet{byName: make(map[string]*metricFamily)}
+}
+
+// family returns the metric family of the specified name. It is created if it
+// does not exist yet.
+func (set *metricSet) family(name, help, typ string) *metricFamily {
+	family, ok := set.byName[name]
+	if !ok {
+		family = &metricFamily{name: name, help: help, typ: typ}
+		set.families = append(set.families, family)
+		set.byName[name] = family
+	}
+	return family
+}
+
+// add adds a sample to the metric family of the specified name.
+func (set *metricSet) add(name, help, typ string, value float64,
+	labels ...label) {
+	set.family(name, help, typ).add("", value, labels...)
+}
+
+// add adds a sample with the specified name suffix.
+func (family *metricFamily) add(suffix string, value float64,
+	labels ...label) {
+	family.samples = append(family.samples,
+		sample{suffix: suffix, labels: labels, value: value})
+}
+
+// write writes all metric families in text exposition format.
+func (set *metricSet) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range set.families {
+		if family.help != "" {
+			bw.WriteString("# HELP " + family.name + " " +
+				escapeHelp(family.help) + "\n")
+		}
+		bw.WriteString("# TYPE " + family.name + " " + family.typ + "\n")
+		for _, sample := range family.samples {
+			bw.WriteString(family.name + sample.suffix)
+			if len(sample.labels) > 0 {
+				bw.WriteString("{")
+				for i, label := range sample.labels {
+					if i > 0 {
+						bw.WriteString(",")
+					}
+					bw.WriteString(label.name + "=\"" +
+						escapeLabelValue(label.value) + "\"")
+				}
+				bw.WriteString("}")
+			}
+			bw.WriteString(" " + formatValue(sample.value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// escapeHelp escapes backslashes and line feeds in help texts.
+func escapeHelp(help string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(help)
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds in label
+// values.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n",
+		"\\n").Replace(value)
+}
+
+// formatValue formats a sample value.
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,88 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Prometheus exporter for PCIExpress devices. It serves device presence, link
+// speed and width, AER error counters, BAR registers selected in a
+// configuration file (see config.go) and DMA transfer metrics of applications
+// using gopcie in the Prometheus text exposition format.
+//
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// read command line arguments
+	var configFilename, listenAddr, metricsPath string
+	flag.StringVar(&configFilename, "config", "", "configuration filename")
+	flag.StringVar(&listenAddr, "listen", ":9437", "listen address")
+	flag.StringVar(&metricsPath, "path", "/metrics", "metrics path")
+	flag.Parse()
+
+	// make sure parameters are set
+	if len(configFilename) == 0 {
+		flag.Usage()
+		return
+	}
+
+	// read configuration
+	cfg, err := readConfig(configFilename)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	c := newCollector(cfg)
+	defer c.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, c)
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+
+	// shut down on SIGINT and SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.Close()
+	}()
+
+	log.Printf("serving metrics on %s%s", listenAddr, metricsPath)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,179 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Command-line utility to test the memory of a PCIExpress device via DMA
+// transfers.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aoeldemann/gopcie"
+	"github.com/aoeldemann/gopcie/memtest"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	// read command line arguments
+	var addrStr, sizeStr, chunkSizeStr, regionSizeStr string
+	var device, writeDevices, readDevices, patterns string
+	var seed int64
+	var accesses int
+	var retentionDelay time.Duration
+	flag.StringVar(&addrStr, "addr", "", "address")
+	flag.StringVar(&sizeStr, "size", "", "size")
+	flag.StringVar(&device, "device", "", "device")
+	flag.StringVar(&writeDevices, "write-device", "",
+		"comma-separated list of write devices (channels), instead of -device")
+	flag.StringVar(&readDevices, "read-device", "",
+		"comma-separated list of read devices (channels), instead of -device")
+	flag.StringVar(&chunkSizeStr, "chunk", "100000", "transfer size")
+	flag.StringVar(&regionSizeStr, "region", "",
+		"size of the regions errors are reported for (default: size)")
+	flag.StringVar(&patterns, "patterns",
+		strings.Join(memtest.Patterns, ","), "comma-separated test patterns")
+	flag.Int64Var(&seed, "seed", 1, "seed of the pseudo-random patterns")
+	flag.IntVar(&accesses, "accesses", 1024,
+		"number of accesses of the random pattern")
+	flag.DurationVar(&retentionDelay, "retention-delay", 10*time.Second,
+		"delay of the retention pattern")
+	flag.Parse()
+
+	// make sure parameters are set. the memory is either accessed through a
+	// single device or through separate write and read devices (e.g. the h2c
+	// and c2h channels of the xdma driver)
+	splitDevices := len(writeDevices) > 0 || len(readDevices) > 0
+	validDevices := (len(device) > 0 && !splitDevices) ||
+		(len(device) == 0 && len(writeDevices) > 0 && len(readDevices) > 0)
+	if len(addrStr) == 0 || len(sizeStr) == 0 || !validDevices {
+		flag.Usage()
+		return
+	}
+
+	// convert hex addr string to int
+	addr, err := gopcie.HexStringToInt(addrStr)
+	if err != nil {
+		panic("invalid address")
+	}
+
+	// convert hex size string to int
+	size, err := gopcie.HexStringToInt(sizeStr)
+	if err != nil {
+		panic("invalid size")
+	}
+
+	// convert hex chunk size string to int
+	chunkSize, err := gopcie.HexStringToInt(chunkSizeStr)
+	if err != nil {
+		panic("invalid chunk size")
+	}
+
+	// convert hex region size string to int
+	var regionSize uint64
+	if len(regionSizeStr) > 0 {
+		regionSize, err = gopcie.HexStringToInt(regionSizeStr)
+		if err != nil {
+			panic("invalid region size")
+		}
+	}
+
+	// create and open pcie device(s). the transfer buffers are taken from
+	// the buffer pool of the (first write) device
+	var mem memtest.Memory
+	var poolDev *gopcie.PCIeDMA
+	if splitDevices {
+		mc, err := gopcie.PCIeDMAMultiChannelOpen(
+			strings.Split(writeDevices, ","), strings.Split(readDevices, ","),
+			0)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer mc.Close()
+		mem, poolDev = mc, mc.WriteChannels()[0]
+	} else {
+		dev, err := gopcie.PCIeDMAOpen(device,
+			gopcie.PCIE_ACCESS_READ|gopcie.PCIE_ACCESS_WRITE)
+		if err != nil {
+			panic(err.Error())
+		}
+		defer dev.Close()
+		mem, poolDev = dev, dev
+	}
+	pool, err := poolDev.BufferPool()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// run memory test
+	fmt.Printf("testing 0x%x bytes at 0x%x (seed %d)\n", size, addr, seed)
+	results, err := memtest.Run(mem, memtest.Config{
+		Addr:           addr,
+		Size:           size,
+		ChunkSize:      int(chunkSize),
+		RegionSize:     regionSize,
+		Patterns:       strings.Split(patterns, ","),
+		Seed:           seed,
+		RandomAccesses: accesses,
+		RetentionDelay: &retentionDelay,
+		BufferPool:     pool,
+	})
+
+	// print report
+	nErrors := uint64(0)
+	for _, result := range results {
+		nErrors += result.Errors
+		if result.Errors == 0 {
+			fmt.Printf("%-14s ok (%s)\n", result.Pattern, result.Duration)
+			continue
+		}
+		fmt.Printf("%-14s %d errors (%s)\n", result.Pattern, result.Errors,
+			result.Duration)
+		for _, region := range result.Regions {
+			if region.Errors == 0 {
+				continue
+			}
+			fmt.Printf("  0x%x-0x%x: %d errors, first at 0x%x (expected "+
+				"0x%016x, read 0x%016x)\n", region.Addr,
+				region.Addr+region.Size-1, region.Errors, region.First.Addr,
+				region.First.Expected, region.First.Actual)
+		}
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+	if nErrors > 0 {
+		os.Exit(1)
+	}
+}
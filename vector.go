@@ -0,0 +1,251 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Vectored DMA transfers. WriteV and ReadV transfer a list of buffers from/to
+// consecutive card addresses with preadv/pwritev, so that e.g. header, payload
+// and trailer do not have to be copied into a single buffer first.
+// WriteSegments and ReadSegments transfer a list of segments targeting
+// arbitrary card addresses. Segments at consecutive card addresses are merged
+// into a single vectored transfer.
+//
+
+package gopcie
+
+import (
+	"io"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// pcieDMAIovMax is the maximum number of buffers transferred by a single
+// preadv/pwritev system call (IOV_MAX).
+const pcieDMAIovMax = 1024
+
+// PCIeDMASegment is a buffer transferred from/to a card address.
+type PCIeDMASegment struct {
+	Addr uint64 // card address
+	Data []byte // data to be written or buffer for read data
+}
+
+// WriteV performs a DMA write transfer of the buffers to consecutive card
+// addresses starting at addr. Transfers larger than the maximum transfer size
+// are split into chunks (see SetMaxTransferSize). If the transfer fails, the
+// returned *PCIeTransferError reports how many bytes were written.
+func (dev *PCIeDMA) WriteV(addr uint64, bufs [][]byte) error {
+	// check if access mode allows writing
+	if (dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
+		return ErrNotWritable
+	}
+
+	// perform write transfer
+	_, err := dev.transferV("write", addr, bufs)
+	return err
+}
+
+// ReadV performs a DMA read transfer from consecutive card addresses starting
+// at addr into the buffers. Transfers larger than the maximum transfer size are
+// split into chunks (see SetMaxTransferSize). If the transfer fails, the
+// returned *PCIeTransferError reports how many bytes were read.
+func (dev *PCIeDMA) ReadV(addr uint64, bufs [][]byte) error {
+	// check if access mode allows reading
+	if (dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return ErrNotReadable
+	}
+
+	// perform read transfer
+	_, err := dev.transferV("read", addr, bufs)
+	return err
+}
+
+// WriteSegments performs DMA write transfers of all segments. Segments are
+// transferred in order. If the transfer of a segment fails, the remaining
+// segments are not transferred and the returned *PCIeTransferError reports the
+// card address of the failed transfer and how many bytes were written.
+func (dev *PCIeDMA) WriteSegments(segs []PCIeDMASegment) error {
+	// check if access mode allows writing
+	if (dev.accessMode & PCIE_ACCESS_WRITE) == 0 {
+		return ErrNotWritable
+	}
+	return dev.transferSegments("write", segs)
+}
+
+// ReadSegments performs DMA read transfers of all segments. Segments are
+// transferred in order. If the transfer of a segment fails, the remaining
+// segments are not transferred and the returned *PCIeTransferError reports the
+// card address of the failed transfer and how many bytes were read.
+func (dev *PCIeDMA) ReadSegments(segs []PCIeDMASegment) error {
+	// check if access mode allows reading
+	if (dev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return ErrNotReadable
+	}
+	return dev.transferSegments("read", segs)
+}
+
+// transferSegments merges segments at consecutive card addresses and
+// transfers each run of merged segments with a single vectored transfer.
+func (dev *PCIeDMA) transferSegments(op string, segs []PCIeDMASegment) error {
+	for len(segs) > 0 {
+		// find run of segments at consecutive card addresses
+		bufs := [][]byte{segs[0].Data}
+		addrNext := segs[0].Addr + uint64(len(segs[0].Data))
+		n := 1
+		for n < len(segs) && segs[n].Addr == addrNext {
+			bufs = append(bufs, segs[n].Data)
+			addrNext += uint64(len(segs[n].Data))
+			n++
+		}
+
+		if _, err := dev.transferV(op, segs[0].Addr, bufs); err != nil {
+			return err
+		}
+		segs = segs[n:]
+	}
+	return nil
+}
+
+// transferV performs a vectored DMA transfer to consecutive card addresses and
+// records it in the device's metrics.
+func (dev *PCIeDMA) transferV(op string, addr uint64,
+	bufs [][]byte) (int, error) {
+	size := 0
+	for _, buf := range bufs {
+		size += len(buf)
+	}
+
+	startTime := time.Now()
+	n, err := dev.transferVChunked(op, addr, bufs, size)
+	dev.recordTransfer(op, size, n, err, time.Since(startTime))
+	return n, err
+}
+
+// transferVChunked performs a vectored DMA transfer of the specified total
+// size to consecutive card addresses. Interrupted and short transfers are
+// retried like in transferChunked.
+func (dev *PCIeDMA) transferVChunked(op string, addr uint64, bufs [][]byte,
+	size int) (int, error) {
+	// get chunking configuration
+	dev.mutex.RLock()
+	maxTransferSize := dev.maxTransferSize
+	transferAlign := dev.transferAlign
+	dev.mutex.RUnlock()
+
+	iovecs := make([]syscall.Iovec, 0, len(bufs))
+	nBytesTransferred := 0
+	nRetries := 0
+	for nBytesTransferred < size {
+		// determine chunk size
+		chunkAddr := addr + uint64(nBytesTransferred)
+		chunkSize := pcieDMAChunkSize(chunkAddr, size-nBytesTransferred,
+			maxTransferSize, transferAlign)
+
+		// collect io vectors of chunk, skipping the bytes already transferred
+		iovecs = iovecs[:0]
+		skip := nBytesTransferred
+		remaining := chunkSize
+		for _, buf := range bufs {
+			if remaining == 0 || len(iovecs) == pcieDMAIovMax {
+				break
+			}
+			if skip >= len(buf) {
+				skip -= len(buf)
+				continue
+			}
+			buf = buf[skip:]
+			skip = 0
+			if len(buf) > remaining {
+				buf = buf[:remaining]
+			}
+			remaining -= len(buf)
+
+			iovec := syscall.Iovec{Base: &buf[0]}
+			iovec.SetLen(len(buf))
+			iovecs = append(iovecs, iovec)
+		}
+
+		// transfer chunk
+		n, err := dev.transferVChunk(op, chunkAddr, iovecs,
+			chunkSize-remaining)
+		nBytesTransferred += n
+
+		// retry interrupted and short transfers, but give up if no progress
+		// is made anymore
+		if err == nil || err == io.EOF || isTemporarySyscallError(err) {
+			if n > 0 {
+				nRetries = 0
+				continue
+			}
+			if nRetries < pcieDMAMaxRetries {
+				nRetries++
+				continue
+			}
+			err = nil
+		}
+		return nBytesTransferred, newPCIeTransferError(op, dev.devName, addr,
+			size, nBytesTransferred, err)
+	}
+	runtime.KeepAlive(bufs)
+	return nBytesTransferred, nil
+}
+
+// transferVChunk transfers a single chunk with preadv/pwritev and supervises
+// it with the watchdog.
+func (dev *PCIeDMA) transferVChunk(op string, addr uint64,
+	iovecs []syscall.Iovec, size int) (int, error) {
+	dev.mutex.RLock()
+	defer dev.mutex.RUnlock()
+
+	// make sure device is still open
+	if dev.fd == nil {
+		return 0, ErrClosed
+	}
+
+	// start watchdog
+	if watchdog := dev.startWatchdog(op, addr, size); watchdog != nil {
+		defer watchdog.Stop()
+	}
+
+	trap := uintptr(syscall.SYS_PREADV)
+	if op == "write" {
+		trap = syscall.SYS_PWRITEV
+	}
+
+	// the offset is passed as low and high word for compatibility with 32 bit
+	// systems
+	n, _, errno := syscall.Syscall6(trap, dev.fd.Fd(),
+		uintptr(unsafe.Pointer(&iovecs[0])), uintptr(len(iovecs)),
+		uintptr(addr), uintptr(addr>>32), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
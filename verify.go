@@ -0,0 +1,167 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Verified DMA transfers. Card memory is read back and compared against the
+// expected data. Mismatches are reported by a *PCIeMismatchError holding the
+// number of mismatching bytes and the first mismatching card addresses.
+//
+
+package gopcie
+
+import (
+	"fmt"
+)
+
+// pcieMismatchMaxReported is the maximum number of mismatches recorded in
+// a PCIeMismatchError.
+const pcieMismatchMaxReported = 16
+
+// pcieVerifyChunkSize is the maximum size of the transfers reading back card
+// memory for comparison.
+const pcieVerifyChunkSize = 1 << 20
+
+// PCIeMismatch is a mismatching byte of card memory.
+type PCIeMismatch struct {
+	Addr     uint64 // card address
+	Expected byte   // expected value
+	Actual   byte   // value read from card memory
+}
+
+// PCIeMismatchError reports card memory not matching the expected data.
+type PCIeMismatchError struct {
+	Path       string         // device path
+	Addr       uint64         // card address of the compared range
+	Size       int            // size of the compared range
+	Count      int            // number of mismatching bytes
+	Mismatches []PCIeMismatch // first mismatching bytes
+}
+
+func (e *PCIeMismatchError) Error() string {
+	return fmt.Sprintf("verify %s at 0x%x: %d of %d bytes mismatch (first "+
+		"at 0x%x)", e.Path, e.Addr, e.Count, e.Size, e.Mismatches[0].Addr)
+}
+
+// Unwrap returns ErrMismatch.
+func (e *PCIeMismatchError) Unwrap() error {
+	return ErrMismatch
+}
+
+// Verify reads card memory starting at addr and compares it against the
+// expected data. If card memory does not match, a *PCIeMismatchError is
+// returned. Card memory is read back and compared in chunks of at most 1 MByte,
+// the read back buffer is taken from the device's buffer pool (see
+// BufferPool).
+func (dev *PCIeDMA) Verify(addr uint64, expected []byte) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	// get read back buffer
+	chunkSize := len(expected)
+	if chunkSize > pcieVerifyChunkSize {
+		chunkSize = pcieVerifyChunkSize
+	}
+	pool, err := dev.BufferPool()
+	if err != nil {
+		return err
+	}
+	buf, err := pool.Get(uint64(chunkSize))
+	if err != nil {
+		return err
+	}
+	defer pool.Put(buf)
+
+	// read back and compare card memory chunk by chunk
+	mismatchErr := &PCIeMismatchError{
+		Path: dev.devName,
+		Addr: addr,
+		Size: len(expected),
+	}
+	for offset := 0; offset < len(expected); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(expected) {
+			end = len(expected)
+		}
+		actual := buf.Bytes()[:end-offset]
+		if err := dev.Read(addr+uint64(offset), actual); err != nil {
+			return err
+		}
+		mismatchErr.compare(addr+uint64(offset), expected[offset:end], actual)
+	}
+
+	if mismatchErr.Count > 0 {
+		return mismatchErr
+	}
+	return nil
+}
+
+// WriteVerify performs a DMA write transfer like Write, reads the written card
+// memory back and compares it (see Verify). The device must have been opened
+// for reading and writing.
+func (dev *PCIeDMA) WriteVerify(addr uint64, data []byte) error {
+	return dev.WriteVerifyVia(dev, addr, data)
+}
+
+// WriteVerifyVia performs a DMA write transfer like Write and reads the written
+// card memory back through the read device for comparison (see Verify). This
+// allows to verify writes on drivers with separate write and read channels
+// (e.g. XDMA's h2c and c2h devices). The read device must have been opened for
+// reading.
+func (dev *PCIeDMA) WriteVerifyVia(readDev *PCIeDMA, addr uint64,
+	data []byte) error {
+	// check if access mode allows reading back the written data
+	if (readDev.accessMode & PCIE_ACCESS_READ) == 0 {
+		return ErrNotReadable
+	}
+
+	if err := dev.Write(addr, data); err != nil {
+		return err
+	}
+	return readDev.Verify(addr, data)
+}
+
+// compare compares card memory read from addr against the expected data and
+// records mismatching bytes.
+func (e *PCIeMismatchError) compare(addr uint64, expected, actual []byte) {
+	for i := range expected {
+		if expected[i] == actual[i] {
+			continue
+		}
+		e.Count++
+		if len(e.Mismatches) < pcieMismatchMaxReported {
+			e.Mismatches = append(e.Mismatches, PCIeMismatch{
+				Addr:     addr + uint64(i),
+				Expected: expected[i],
+				Actual:   actual[i],
+			})
+		}
+	}
+}
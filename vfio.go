@@ -0,0 +1,591 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// VFIO backend. The device is opened through its IOMMU group, which gives
+// unprivileged access to the BARs (once the device is bound to vfio-pci and
+// the user has permissions on /dev/vfio/<group>). Host memory buffers are
+// mapped into the device's I/O virtual address space through the IOMMU of the
+// VFIO container, so that the device can master DMA transfers to/from them.
+//
+
+package gopcie
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// VFIO ioctl request numbers (see linux/vfio.h).
+const (
+	vfioGetApiVersion       = 0x3b64
+	vfioCheckExtension      = 0x3b65
+	vfioSetIommu            = 0x3b66
+	vfioGroupGetStatus      = 0x3b67
+	vfioGroupSetContainer   = 0x3b68
+	vfioGroupUnsetContainer = 0x3b69
+	vfioGroupGetDeviceFd    = 0x3b6a
+	vfioDeviceGetInfo       = 0x3b6b
+	vfioDeviceGetRegionInfo = 0x3b6c
+	vfioDeviceReset         = 0x3b6f
+	vfioIommuMapDma         = 0x3b71
+	vfioIommuUnmapDma       = 0x3b72
+	vfioApiVersion          = 0
+	vfioType1Iommu          = 1
+	vfioGroupFlagsViable    = 1 << 0
+	vfioRegionInfoFlagMmap  = 1 << 2
+	vfioDmaMapFlagRead      = 1 << 0
+	vfioDmaMapFlagWrite     = 1 << 1
+	vfioPciNumBars          = 6
+	vfioContainerDevicePath = "/dev/vfio/vfio"
+	vfioGroupDevicePath     = "/dev/vfio"
+)
+
+// vfioGroupStatus corresponds to struct vfio_group_status.
+type vfioGroupStatus struct {
+	argsz uint32
+	flags uint32
+}
+
+// vfioDeviceInfo corresponds to struct vfio_device_info.
+type vfioDeviceInfo struct {
+	argsz      uint32
+	flags      uint32
+	numRegions uint32
+	numIrqs    uint32
+}
+
+// vfioRegionInfo corresponds to struct vfio_region_info.
+type vfioRegionInfo struct {
+	argsz     uint32
+	flags     uint32
+	index     uint32
+	capOffset uint32
+	size      uint64
+	offset    uint64
+}
+
+// vfioDmaMap corresponds to struct vfio_iommu_type1_dma_map.
+type vfioDmaMap struct {
+	argsz uint32
+	flags uint32
+	vaddr uint64
+	iova  uint64
+	size  uint64
+}
+
+// vfioDmaUnmap corresponds to struct vfio_iommu_type1_dma_unmap.
+type vfioDmaUnmap struct {
+	argsz uint32
+	flags uint32
+	iova  uint64
+	size  uint64
+}
+
+// vfioSys abstracts the system calls issued by the VFIO backend, so that they
+// can be replaced by a fake implementation when no VFIO device is available.
+type vfioSys interface {
+	// Open opens a VFIO character device.
+	Open(path string) (*os.File, error)
+	// Ioctl issues an ioctl with a pointer argument.
+	Ioctl(fd, req uintptr, arg unsafe.Pointer) (uintptr, error)
+	// IoctlInt issues an ioctl with an integer argument.
+	IoctlInt(fd, req, arg uintptr) (uintptr, error)
+	// Dup duplicates a file descriptor.
+	Dup(fd uintptr) (uintptr, error)
+	// Mmap memory-maps a region of a file.
+	Mmap(fd uintptr, offset int64, length int) ([]byte, error)
+	// MmapAnon allocates anonymous memory by memory-mapping it.
+	MmapAnon(length int) ([]byte, error)
+	// Munmap un-memory-maps a region previously mapped by Mmap or MmapAnon.
+	Munmap(data []byte) error
+	// IommuGroup returns the IOMMU group of a device.
+	IommuGroup(devAddr string) (string, error)
+}
+
+// vfioSysLinux implements vfioSys using the Linux system calls.
+type vfioSysLinux struct{}
+
+func (vfioSysLinux) Open(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
+
+func (vfioSysLinux) Ioctl(fd, req uintptr, arg unsafe.Pointer) (uintptr,
+	error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return r, errno
+	}
+	return r, nil
+}
+
+func (vfioSysLinux) IoctlInt(fd, req, arg uintptr) (uintptr, error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if errno != 0 {
+		return r, errno
+	}
+	return r, nil
+}
+
+func (vfioSysLinux) Dup(fd uintptr) (uintptr, error) {
+	newFd, err := syscall.Dup(int(fd))
+	return uintptr(newFd), err
+}
+
+func (vfioSysLinux) Mmap(fd uintptr, offset int64, length int) ([]byte,
+	error) {
+	return syscall.Mmap(int(fd), offset, length,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func (vfioSysLinux) MmapAnon(length int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, length, syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED|syscall.MAP_ANONYMOUS)
+}
+
+func (vfioSysLinux) Munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+func (vfioSysLinux) IommuGroup(devAddr string) (string, error) {
+	return pcieSysfsIommuGroup(devAddr)
+}
+
+// vfioSysDefault is the system call layer used by newly opened VFIO devices.
+var vfioSysDefault vfioSys = vfioSysLinux{}
+
+// VFIODevice is a PCIExpress device opened through VFIO.
+type VFIODevice struct {
+	sys       vfioSys
+	container *os.File
+	group     *os.File
+	dev       *os.File
+	devAddr   string
+
+	// operations in progress are counted, so that Close can wait for them to
+	// complete after marking the device closed, before the files are closed
+	closed atomic.Bool
+	active atomic.Int64
+}
+
+// VFIOOpen opens a PCIExpress device through VFIO. The function expects the
+// function, vendor and device ID of the device to be opened. The device must
+// be bound to the vfio-pci driver.
+func VFIOOpen(functionId, vendorId, deviceId uint) (*VFIODevice, error) {
+	// find the device in sysfs
+	devAddr, err := pcieDeviceFind(functionId, vendorId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	return VFIOOpenAddr(devAddr)
+}
+
+// VFIOOpenAddr opens a PCIExpress device through VFIO. The function expects the
+// PCI address of the device (e.g. "0000:01:00.0").
+func VFIOOpenAddr(devAddr string) (*VFIODevice, error) {
+	return vfioOpen(vfioSysDefault, devAddr)
+}
+
+// vfioOpen opens a PCIExpress device through VFIO using the specified system
+// call layer.
+func vfioOpen(sys vfioSys, devAddr string) (*VFIODevice, error) {
+	// determine IOMMU group of the device
+	groupId, err := sys.IommuGroup(devAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// open container
+	container, err := sys.Open(vfioContainerDevicePath)
+	if err != nil {
+		return nil, newPCIeError("open vfio container",
+			vfioContainerDevicePath, err)
+	}
+
+	// make sure container supports the expected API version and the type 1
+	// IOMMU
+	version, err := sys.IoctlInt(container.Fd(), vfioGetApiVersion, 0)
+	if err == nil && version != vfioApiVersion {
+		err = ErrUnsupported
+	}
+	if err != nil {
+		container.Close()
+		return nil, newPCIeError("check vfio api version",
+			vfioContainerDevicePath, err)
+	}
+	supported, err := sys.IoctlInt(container.Fd(), vfioCheckExtension,
+		vfioType1Iommu)
+	if err == nil && supported == 0 {
+		err = ErrUnsupported
+	}
+	if err != nil {
+		container.Close()
+		return nil, newPCIeError("check vfio type 1 iommu support",
+			vfioContainerDevicePath, err)
+	}
+
+	// open group
+	groupName := filepath.Join(vfioGroupDevicePath, groupId)
+	group, err := sys.Open(groupName)
+	if err != nil {
+		container.Close()
+		return nil, newPCIeError("open vfio group", groupName, err)
+	}
+
+	// make sure group is viable (i.e. all devices in the group are bound to
+	// vfio)
+	status := vfioGroupStatus{argsz: uint32(unsafe.Sizeof(vfioGroupStatus{}))}
+	_, err = sys.Ioctl(group.Fd(), vfioGroupGetStatus, unsafe.Pointer(&status))
+	if err == nil && (status.flags&vfioGroupFlagsViable) == 0 {
+		err = ErrUnsupported
+	}
+	if err != nil {
+		group.Close()
+		container.Close()
+		return nil, newPCIeError("check vfio group viability", groupName, err)
+	}
+
+	// add group to container
+	containerFd := int32(container.Fd())
+	_, err = sys.Ioctl(group.Fd(), vfioGroupSetContainer,
+		unsafe.Pointer(&containerFd))
+	if err != nil {
+		group.Close()
+		container.Close()
+		return nil, newPCIeError("add vfio group to container", groupName,
+			err)
+	}
+
+	// enable IOMMU
+	_, err = sys.IoctlInt(container.Fd(), vfioSetIommu, vfioType1Iommu)
+	if err != nil {
+		sys.IoctlInt(group.Fd(), vfioGroupUnsetContainer, 0)
+		group.Close()
+		container.Close()
+		return nil, newPCIeError("set vfio iommu type",
+			vfioContainerDevicePath, err)
+	}
+
+	// get device file descriptor
+	devAddrC := append([]byte(devAddr), 0)
+	devFd, err := sys.Ioctl(group.Fd(), vfioGroupGetDeviceFd,
+		unsafe.Pointer(&devAddrC[0]))
+	if err != nil {
+		sys.IoctlInt(group.Fd(), vfioGroupUnsetContainer, 0)
+		group.Close()
+		container.Close()
+		return nil, newPCIeError("get vfio device", devAddr, err)
+	}
+
+	return &VFIODevice{
+		sys:       sys,
+		container: container,
+		group:     group,
+		dev:       os.NewFile(devFd, devAddr),
+		devAddr:   devAddr,
+	}, nil
+}
+
+// Close closes the VFIO device. DMA buffers obtained from the device must be
+// closed beforehand. Closing an already closed device has no effect.
+func (dev *VFIODevice) Close() error {
+	if !dev.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// wait for operations in progress. operations starting after the device
+	// has been marked closed fail
+	for dev.active.Load() != 0 {
+		runtime.Gosched()
+	}
+
+	// close all files, even if closing one of them fails
+	errDev := dev.dev.Close()
+	dev.sys.IoctlInt(dev.group.Fd(), vfioGroupUnsetContainer, 0)
+	errGroup := dev.group.Close()
+	errContainer := dev.container.Close()
+
+	if errDev != nil {
+		return newPCIeError("close vfio device", dev.devAddr, errDev)
+	}
+	if errGroup != nil {
+		return newPCIeError("close vfio group", dev.group.Name(), errGroup)
+	}
+	if errContainer != nil {
+		return newPCIeError("close vfio container", vfioContainerDevicePath,
+			errContainer)
+	}
+	return nil
+}
+
+// acquire registers an operation on the device. It returns an error if the
+// device has been closed.
+func (dev *VFIODevice) acquire(op string) error {
+	// count the operation before checking the closed flag, so that Close
+	// either waits for the operation or the operation sees the device closed
+	dev.active.Add(1)
+	if dev.closed.Load() {
+		dev.active.Add(-1)
+		return newPCIeError(op, dev.devAddr, ErrClosed)
+	}
+	return nil
+}
+
+// release marks an operation registered by acquire as completed.
+func (dev *VFIODevice) release() {
+	dev.active.Add(-1)
+}
+
+// Reset resets the device.
+func (dev *VFIODevice) Reset() error {
+	if err := dev.acquire("reset vfio device"); err != nil {
+		return err
+	}
+	defer dev.release()
+
+	_, err := dev.sys.IoctlInt(dev.dev.Fd(), vfioDeviceReset, 0)
+	if err != nil {
+		return newPCIeError("reset vfio device", dev.devAddr, err)
+	}
+	return nil
+}
+
+// BAROpen memory-maps a base address register of the device. The returned
+// PCIeBAR is used exactly like one returned by PCIeBAROpen.
+func (dev *VFIODevice) BAROpen(barId uint) (*PCIeBAR, error) {
+	if err := dev.acquire("open BAR"); err != nil {
+		return nil, err
+	}
+	defer dev.release()
+
+	if barId >= vfioPciNumBars {
+		return nil, newPCIeError("open BAR", dev.devAddr, ErrBARNotFound)
+	}
+
+	// get device info to make sure region exists
+	devInfo := vfioDeviceInfo{argsz: uint32(unsafe.Sizeof(vfioDeviceInfo{}))}
+	_, err := dev.sys.Ioctl(dev.dev.Fd(), vfioDeviceGetInfo,
+		unsafe.Pointer(&devInfo))
+	if err != nil {
+		return nil, newPCIeError("get vfio device info", dev.devAddr, err)
+	}
+	if uint32(barId) >= devInfo.numRegions {
+		return nil, newPCIeError("open BAR", dev.devAddr, ErrBARNotFound)
+	}
+
+	// get BAR region info
+	regionInfo := vfioRegionInfo{
+		argsz: uint32(unsafe.Sizeof(vfioRegionInfo{})),
+		index: uint32(barId),
+	}
+	_, err = dev.sys.Ioctl(dev.dev.Fd(), vfioDeviceGetRegionInfo,
+		unsafe.Pointer(&regionInfo))
+	if err != nil {
+		return nil, newPCIeError("get vfio region info", dev.devAddr, err)
+	}
+	if regionInfo.size == 0 {
+		return nil, newPCIeError("open BAR", dev.devAddr, ErrBARNotFound)
+	}
+	if (regionInfo.flags & vfioRegionInfoFlagMmap) == 0 {
+		return nil, newPCIeError("memory-map BAR", dev.devAddr, ErrUnsupported)
+	}
+
+	// memory-map the BAR
+	bar, err := dev.sys.Mmap(dev.dev.Fd(), int64(regionInfo.offset),
+		int(regionInfo.size))
+	if err != nil {
+		return nil, newPCIeError("memory-map BAR", dev.devAddr, err)
+	}
+
+	// the BAR gets its own reference to the device file, so that closing the
+	// BAR and the device is independent of each other
+	fd, err := dev.sys.Dup(dev.dev.Fd())
+	if err != nil {
+		dev.sys.Munmap(bar)
+		return nil, newPCIeError("duplicate file descriptor", dev.devAddr, err)
+	}
+
+	pcieBAR := newPCIeBAR(os.NewFile(fd, dev.devAddr), dev.devAddr, bar)
+	pcieBAR.munmap = dev.sys.Munmap
+	return pcieBAR, nil
+}
+
+// VFIODMA is a host memory buffer that is mapped into the I/O virtual address
+// space of a VFIO device, so that the device can read and write it via DMA.
+// Read and Write have the same semantics as the ones of PCIeDMA, except that
+// addresses are I/O virtual addresses rather than card memory addresses.
+type VFIODMA struct {
+	dev  *VFIODevice
+	data []byte
+	iova uint64
+
+	// accesses are counted like the ones of PCIeBAR, so that Close can wait
+	// for them before the buffer is released
+	closed atomic.Bool
+	active atomic.Int64
+}
+
+// DMAAlloc allocates a page-aligned host memory buffer of the specified size
+// and maps it into the device's I/O virtual address space at the specified
+// address. Size and address must be multiples of the page size.
+func (dev *VFIODevice) DMAAlloc(size, iova uint64) (*VFIODMA, error) {
+	if err := dev.acquire("allocate dma buffer"); err != nil {
+		return nil, err
+	}
+	defer dev.release()
+
+	pageSize := uint64(os.Getpagesize())
+	if size == 0 || size%pageSize != 0 || iova%pageSize != 0 {
+		return nil, newPCIeError("allocate dma buffer", dev.devAddr,
+			ErrInvalidArgument)
+	}
+
+	// allocate buffer. memory-mapped memory is never moved by the go runtime
+	data, err := dev.sys.MmapAnon(int(size))
+	if err != nil {
+		return nil, newPCIeError("allocate dma buffer", dev.devAddr, err)
+	}
+
+	// map buffer through the IOMMU
+	dmaMap := vfioDmaMap{
+		argsz: uint32(unsafe.Sizeof(vfioDmaMap{})),
+		flags: vfioDmaMapFlagRead | vfioDmaMapFlagWrite,
+		vaddr: uint64(uintptr(unsafe.Pointer(&data[0]))),
+		iova:  iova,
+		size:  size,
+	}
+	_, err = dev.sys.Ioctl(dev.container.Fd(), vfioIommuMapDma,
+		unsafe.Pointer(&dmaMap))
+	if err != nil {
+		dev.sys.Munmap(data)
+		return nil, newPCIeError("map dma buffer", dev.devAddr, err)
+	}
+
+	return &VFIODMA{dev: dev, data: data, iova: iova}, nil
+}
+
+// Close unmaps the buffer from the device's I/O virtual address space and
+// releases it. If the buffer cannot be unmapped, it is not released (since the
+// device may still access it) and remains open. Closing an already closed
+// buffer has no effect.
+func (buf *VFIODMA) Close() error {
+	if !buf.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// wait for accesses in progress
+	for buf.active.Load() != 0 {
+		runtime.Gosched()
+	}
+
+	dmaUnmap := vfioDmaUnmap{
+		argsz: uint32(unsafe.Sizeof(vfioDmaUnmap{})),
+		iova:  buf.iova,
+		size:  uint64(len(buf.data)),
+	}
+	_, err := buf.dev.sys.Ioctl(buf.dev.container.Fd(), vfioIommuUnmapDma,
+		unsafe.Pointer(&dmaUnmap))
+	if err != nil {
+		buf.closed.Store(false)
+		return newPCIeError("unmap dma buffer", buf.dev.devAddr, err)
+	}
+	if err := buf.dev.sys.Munmap(buf.data); err != nil {
+		return newPCIeError("free dma buffer", buf.dev.devAddr, err)
+	}
+	return nil
+}
+
+// IOVA returns the I/O virtual address of the buffer, which is the address the
+// device must use to access it.
+func (buf *VFIODMA) IOVA() uint64 {
+	return buf.iova
+}
+
+// Bytes returns the host memory of the buffer. It returns nil if the buffer has
+// been closed. The returned memory must not be accessed after closing the
+// buffer.
+func (buf *VFIODMA) Bytes() []byte {
+	if buf.closed.Load() {
+		return nil
+	}
+	return buf.data
+}
+
+// Write copies data into the buffer at the specified I/O virtual address, from
+// where the device can read it.
+func (buf *VFIODMA) Write(addr uint64, data []byte) error {
+	if err := buf.acquire("write", addr, len(data)); err != nil {
+		return err
+	}
+	copy(buf.data[addr-buf.iova:], data)
+	buf.release()
+	return nil
+}
+
+// Read copies data that the device wrote to the buffer at the specified I/O
+// virtual address.
+func (buf *VFIODMA) Read(addr uint64, data []byte) error {
+	if err := buf.acquire("read", addr, len(data)); err != nil {
+		return err
+	}
+	copy(data, buf.data[addr-buf.iova:])
+	buf.release()
+	return nil
+}
+
+// acquire registers an access of the buffer. It returns an error if the buffer
+// has been closed or the access is out of range.
+func (buf *VFIODMA) acquire(op string, addr uint64, size int) error {
+	buf.active.Add(1)
+	if buf.closed.Load() {
+		buf.active.Add(-1)
+		return newPCIeTransferError(op, buf.dev.devAddr, addr, size, 0,
+			ErrClosed)
+	}
+	// compare against the space remaining after the offset, since
+	// offset+size may wrap around for addresses near the top of the iova
+	// space
+	if addr < buf.iova || addr-buf.iova > uint64(len(buf.data)) ||
+		uint64(size) > uint64(len(buf.data))-(addr-buf.iova) {
+		buf.active.Add(-1)
+		return newPCIeTransferError(op, buf.dev.devAddr, addr, size, 0,
+			ErrOutOfRange)
+	}
+	return nil
+}
+
+// release marks an access registered by acquire as completed.
+func (buf *VFIODMA) release() {
+	buf.active.Add(-1)
+}
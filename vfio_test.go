@@ -0,0 +1,435 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Tests of the VFIO backend using a fake system call layer.
+//
+
+package gopcie
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// vfioSysFake implements vfioSys without a VFIO device. Files are backed by
+// /dev/null and memory mappings by go memory. Ioctls listed in fail return
+// the associated error.
+type vfioSysFake struct {
+	fail       map[uintptr]error
+	failMmap   error
+	failDup    error
+	version    uintptr
+	notViable  bool
+	regionInfo vfioRegionInfo
+	mappings   int
+	dmaMaps    map[uint64]uint64
+}
+
+// newVFIOSysFake creates a fake system call layer of a device with a single
+// memory-mappable region of 4096 bytes.
+func newVFIOSysFake() *vfioSysFake {
+	return &vfioSysFake{
+		fail: make(map[uintptr]error),
+		regionInfo: vfioRegionInfo{
+			flags:  vfioRegionInfoFlagMmap,
+			size:   4096,
+			offset: 1 << 40,
+		},
+		dmaMaps: make(map[uint64]uint64),
+	}
+}
+
+func (sys *vfioSysFake) Open(path string) (*os.File, error) {
+	return os.OpenFile(os.DevNull, os.O_RDWR, 0)
+}
+
+func (sys *vfioSysFake) Ioctl(fd, req uintptr, arg unsafe.Pointer) (uintptr,
+	error) {
+	if err := sys.fail[req]; err != nil {
+		return 0, err
+	}
+	switch req {
+	case vfioGroupGetStatus:
+		if !sys.notViable {
+			(*vfioGroupStatus)(arg).flags = vfioGroupFlagsViable
+		}
+	case vfioGroupGetDeviceFd:
+		devFd, err := syscall.Open(os.DevNull, syscall.O_RDWR, 0)
+		return uintptr(devFd), err
+	case vfioDeviceGetInfo:
+		(*vfioDeviceInfo)(arg).numRegions = 9
+	case vfioDeviceGetRegionInfo:
+		info := (*vfioRegionInfo)(arg)
+		info.flags = sys.regionInfo.flags
+		info.size = sys.regionInfo.size
+		info.offset = sys.regionInfo.offset
+	case vfioIommuMapDma:
+		dmaMap := (*vfioDmaMap)(arg)
+		sys.dmaMaps[dmaMap.iova] = dmaMap.size
+	case vfioIommuUnmapDma:
+		delete(sys.dmaMaps, (*vfioDmaUnmap)(arg).iova)
+	}
+	return 0, nil
+}
+
+func (sys *vfioSysFake) IoctlInt(fd, req, arg uintptr) (uintptr, error) {
+	if err := sys.fail[req]; err != nil {
+		return 0, err
+	}
+	switch req {
+	case vfioGetApiVersion:
+		return sys.version, nil
+	case vfioCheckExtension:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (sys *vfioSysFake) Dup(fd uintptr) (uintptr, error) {
+	if sys.failDup != nil {
+		return 0, sys.failDup
+	}
+	newFd, err := syscall.Dup(int(fd))
+	return uintptr(newFd), err
+}
+
+func (sys *vfioSysFake) Mmap(fd uintptr, offset int64, length int) ([]byte,
+	error) {
+	return sys.MmapAnon(length)
+}
+
+func (sys *vfioSysFake) MmapAnon(length int) ([]byte, error) {
+	if sys.failMmap != nil {
+		return nil, sys.failMmap
+	}
+	sys.mappings++
+	return make([]byte, length), nil
+}
+
+func (sys *vfioSysFake) Munmap(data []byte) error {
+	sys.mappings--
+	return nil
+}
+
+func (sys *vfioSysFake) IommuGroup(devAddr string) (string, error) {
+	return "42", nil
+}
+
+func TestVFIOOpenClose(t *testing.T) {
+	sys := newVFIOSysFake()
+	leaks := newLeakChecker(t, "")
+
+	dev, err := vfioOpen(sys, "0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(3, 0)
+
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(0, 0)
+
+	// closing twice has no effect
+	if err := dev.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	if err := dev.Reset(); !errors.Is(err, ErrClosed) {
+		t.Errorf("reset after close: %v, expected ErrClosed", err)
+	}
+	if _, err := dev.BAROpen(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("BAR open after close: %v, expected ErrClosed", err)
+	}
+	if _, err := dev.DMAAlloc(4096, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("dma alloc after close: %v, expected ErrClosed", err)
+	}
+}
+
+func TestVFIOOpenFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(sys *vfioSysFake)
+		err   error
+	}{
+		{"api version ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioGetApiVersion] = syscall.EIO
+		}, syscall.EIO},
+		{"api version mismatch", func(sys *vfioSysFake) {
+			sys.version = 1
+		}, ErrUnsupported},
+		{"check extension ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioCheckExtension] = syscall.EIO
+		}, syscall.EIO},
+		{"group status ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioGroupGetStatus] = syscall.EIO
+		}, syscall.EIO},
+		{"group not viable", func(sys *vfioSysFake) {
+			sys.notViable = true
+		}, ErrUnsupported},
+		{"set container ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioGroupSetContainer] = syscall.EBUSY
+		}, syscall.EBUSY},
+		{"set iommu ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioSetIommu] = syscall.EIO
+		}, syscall.EIO},
+		{"get device fd ioctl", func(sys *vfioSysFake) {
+			sys.fail[vfioGroupGetDeviceFd] = syscall.ENODEV
+		}, syscall.ENODEV},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newVFIOSysFake()
+			test.setup(sys)
+			leaks := newLeakChecker(t, "")
+
+			_, err := vfioOpen(sys, "0000:01:00.0")
+			if !errors.Is(err, test.err) {
+				t.Errorf("open: %v, expected %v", err, test.err)
+			}
+			leaks.check(0, 0)
+		})
+	}
+}
+
+func TestVFIOBAROpen(t *testing.T) {
+	sys := newVFIOSysFake()
+	dev, err := vfioOpen(sys, "0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	leaks := newLeakChecker(t, "")
+
+	bar, err := dev.BAROpen(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaks.check(1, 0)
+	if bar.Size() != 4096 || sys.mappings != 1 {
+		t.Errorf("BAR size %d, %d mappings", bar.Size(), sys.mappings)
+	}
+	bar.Write(4092, 0xdeadbeef)
+	if data := bar.Read(4092); data != 0xdeadbeef {
+		t.Errorf("read 0x%08x, expected 0xdeadbeef", data)
+	}
+
+	// the BAR is unmapped through the system call layer
+	if err := bar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sys.mappings != 0 {
+		t.Errorf("%d mappings after close", sys.mappings)
+	}
+	leaks.check(0, 0)
+
+	// the BAR remains valid after closing the device
+	bar, err = dev.BAROpen(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev.Close()
+	bar.Write(0, 1)
+	bar.Close()
+}
+
+func TestVFIOBAROpenFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		barId uint
+		setup func(sys *vfioSysFake)
+		err   error
+	}{
+		{"invalid BAR", vfioPciNumBars, func(sys *vfioSysFake) {}, ErrBARNotFound},
+		{"device info ioctl", 0, func(sys *vfioSysFake) {
+			sys.fail[vfioDeviceGetInfo] = syscall.EIO
+		}, syscall.EIO},
+		{"region info ioctl", 0, func(sys *vfioSysFake) {
+			sys.fail[vfioDeviceGetRegionInfo] = syscall.EINVAL
+		}, syscall.EINVAL},
+		{"empty region", 0, func(sys *vfioSysFake) {
+			sys.regionInfo.size = 0
+		}, ErrBARNotFound},
+		{"region not mappable", 0, func(sys *vfioSysFake) {
+			sys.regionInfo.flags = 0
+		}, ErrUnsupported},
+		{"mmap", 0, func(sys *vfioSysFake) {
+			sys.failMmap = syscall.ENOMEM
+		}, syscall.ENOMEM},
+		{"dup", 0, func(sys *vfioSysFake) {
+			sys.failDup = syscall.EMFILE
+		}, syscall.EMFILE},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newVFIOSysFake()
+			dev, err := vfioOpen(sys, "0000:01:00.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dev.Close()
+			test.setup(sys)
+			leaks := newLeakChecker(t, "")
+
+			_, err = dev.BAROpen(test.barId)
+			if !errors.Is(err, test.err) {
+				t.Errorf("BAR open: %v, expected %v", err, test.err)
+			}
+			if sys.mappings != 0 {
+				t.Errorf("%d mappings after failure", sys.mappings)
+			}
+			leaks.check(0, 0)
+		})
+	}
+}
+
+func TestVFIODMAAlloc(t *testing.T) {
+	sys := newVFIOSysFake()
+	dev, err := vfioOpen(sys, "0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+
+	buf, err := dev.DMAAlloc(8192, 0x10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.dmaMaps[0x10000] != 8192 || sys.mappings != 1 {
+		t.Errorf("iommu mappings %v, %d mappings", sys.dmaMaps, sys.mappings)
+	}
+
+	data := []byte{1, 2, 3, 4}
+	if err := buf.Write(0x11ffc, data); err != nil {
+		t.Fatal(err)
+	}
+	readData := make([]byte, 4)
+	if err := buf.Read(0x11ffc, readData); err != nil {
+		t.Fatal(err)
+	}
+	if string(readData) != string(data) {
+		t.Errorf("read %v, expected %v", readData, data)
+	}
+	if err := buf.Write(0x12000, data); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("write out of range: %v, expected ErrOutOfRange", err)
+	}
+	if err := buf.Read(0xfffc, readData); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("read out of range: %v, expected ErrOutOfRange", err)
+	}
+
+	// the end of an access near the top of the iova space wraps around
+	zeroBuf, err := dev.DMAAlloc(8192, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zeroBuf.Write(0xfffffffffffffffc, make([]byte, 8))
+	if !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("wrapping write: %v, expected ErrOutOfRange", err)
+	}
+	if err := zeroBuf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a buffer that cannot be unmapped from the iommu remains open
+	sys.fail[vfioIommuUnmapDma] = syscall.EIO
+	if err := buf.Close(); !errors.Is(err, syscall.EIO) {
+		t.Errorf("close: %v, expected EIO", err)
+	}
+	if err := buf.Read(0x10000, readData); err != nil {
+		t.Errorf("read after failed close: %v", err)
+	}
+	delete(sys.fail, vfioIommuUnmapDma)
+
+	if err := buf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sys.dmaMaps) != 0 || sys.mappings != 0 {
+		t.Errorf("iommu mappings %v, %d mappings after close", sys.dmaMaps,
+			sys.mappings)
+	}
+
+	// closing twice has no effect
+	if err := buf.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	if err := buf.Write(0x10000, data); !errors.Is(err, ErrClosed) {
+		t.Errorf("write after close: %v, expected ErrClosed", err)
+	}
+	if buf.Bytes() != nil {
+		t.Error("bytes after close")
+	}
+}
+
+func TestVFIODMAAllocFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		size, iova uint64
+		setup      func(sys *vfioSysFake)
+		err        error
+	}{
+		{"zero size", 0, 0, func(sys *vfioSysFake) {}, ErrInvalidArgument},
+		{"unaligned size", 100, 0, func(sys *vfioSysFake) {},
+			ErrInvalidArgument},
+		{"unaligned address", 4096, 100, func(sys *vfioSysFake) {},
+			ErrInvalidArgument},
+		{"mmap", 4096, 0, func(sys *vfioSysFake) {
+			sys.failMmap = syscall.ENOMEM
+		}, syscall.ENOMEM},
+		{"iommu map ioctl", 4096, 0, func(sys *vfioSysFake) {
+			sys.fail[vfioIommuMapDma] = syscall.EFAULT
+		}, syscall.EFAULT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newVFIOSysFake()
+			dev, err := vfioOpen(sys, "0000:01:00.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dev.Close()
+			test.setup(sys)
+
+			_, err = dev.DMAAlloc(test.size, test.iova)
+			if !errors.Is(err, test.err) {
+				t.Errorf("dma alloc: %v, expected %v", err, test.err)
+			}
+			if sys.mappings != 0 || len(sys.dmaMaps) != 0 {
+				t.Errorf("iommu mappings %v, %d mappings after failure",
+					sys.dmaMaps, sys.mappings)
+			}
+		})
+	}
+}
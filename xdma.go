@@ -0,0 +1,337 @@
+//
+// The MIT License
+//
+// Copyright (c) 2017-2018 by the author(s)
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+//
+// Author(s):
+//   - Andreas Oeldemann <andreas.oeldemann@tum.de>
+//
+// Date Created:        October 19th 2026
+// Date Last Modified:  October 19th 2026
+//
+// Description:
+//
+// Discovery of Xilinx XDMA driver instances. The driver creates a family of
+// character devices per card:
+//
+//   /dev/xdmaN_h2c_M     host-to-card DMA channels
+//   /dev/xdmaN_c2h_M     card-to-host DMA channels
+//   /dev/xdmaN_user      user BAR (AXI-Lite master)
+//   /dev/xdmaN_control   DMA engine configuration BAR
+//   /dev/xdmaN_bypass    DMA bypass BAR
+//   /dev/xdmaN_events_K  user interrupts
+//
+// XDMAOpen discovers all devices of an instance, memory-maps the BARs and opens
+// the DMA channels and event devices.
+//
+
+package gopcie
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// xdmaDevDir is the directory containing the XDMA character devices.
+const xdmaDevDir = "/dev"
+
+// ioresourceMem is the memory resource flag of the sysfs resource file.
+const ioresourceMem = 0x200
+
+// XDMADevice is an instance of the Xilinx XDMA driver.
+type XDMADevice struct {
+	instance int
+	devAddr  string
+
+	dma     *PCIeDMAMultiChannel
+	user    *PCIeBAR
+	control *PCIeBAR
+	bypass  *PCIeBAR
+	events  []*PCIeEvent
+}
+
+// XDMAOpen opens the XDMA driver instance with the specified number (i.e. the
+// N in /dev/xdmaN_...).
+func XDMAOpen(instance int) (*XDMADevice, error) {
+	// the control device exists for every instance
+	controlDevName := filepath.Join(xdmaDevDir,
+		fmt.Sprintf("xdma%d_control", instance))
+	if _, err := os.Stat(controlDevName); err != nil {
+		if os.IsNotExist(err) {
+			err = ErrDeviceNotFound
+		}
+		return nil, newPCIeError("find xdma instance", controlDevName, err)
+	}
+
+	// determine the pci address of the card
+	devAddr, err := pcieDeviceAddrOfDev(controlDevName)
+	if err != nil {
+		return nil, err
+	}
+
+	return xdmaOpen(instance, devAddr)
+}
+
+// XDMAOpenAddr opens the XDMA driver instance of the card with the specified
+// PCI address (e.g. 0000:01:00.0).
+func XDMAOpenAddr(devAddr string) (*XDMADevice, error) {
+	controlDevNames, err := filepath.Glob(filepath.Join(xdmaDevDir,
+		"xdma*_control"))
+	if err != nil {
+		return nil, newPCIeError("find xdma instance", devAddr, err)
+	}
+
+	for _, controlDevName := range controlDevNames {
+		// skip devices that do not belong to the card
+		addr, err := pcieDeviceAddrOfDev(controlDevName)
+		if err != nil || addr != devAddr {
+			continue
+		}
+
+		instanceStr := strings.TrimSuffix(strings.TrimPrefix(
+			filepath.Base(controlDevName), "xdma"), "_control")
+		instance, err := strconv.Atoi(instanceStr)
+		if err != nil {
+			continue
+		}
+		return xdmaOpen(instance, devAddr)
+	}
+
+	return nil, newPCIeError("find xdma instance", devAddr, ErrDeviceNotFound)
+}
+
+// xdmaOpen opens all devices of the XDMA driver instance.
+func xdmaOpen(instance int, devAddr string) (*XDMADevice, error) {
+	dev := &XDMADevice{instance: instance, devAddr: devAddr}
+	prefix := filepath.Join(xdmaDevDir, fmt.Sprintf("xdma%d_", instance))
+
+	// open dma channels
+	h2cDevNames, err := xdmaGlobSorted(prefix + "h2c_")
+	if err != nil {
+		return nil, err
+	}
+	c2hDevNames, err := xdmaGlobSorted(prefix + "c2h_")
+	if err != nil {
+		return nil, err
+	}
+	if len(h2cDevNames) > 0 || len(c2hDevNames) > 0 {
+		dev.dma, err = PCIeDMAMultiChannelOpen(h2cDevNames, c2hDevNames, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the driver assigns the card's memory BARs in order to the user BAR (if
+	// present), the control BAR and the bypass BAR (if present)
+	barSizes, err := pcieSysfsBARSizes(devAddr)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	bars := []struct {
+		name string
+		bar  **PCIeBAR
+	}{
+		{"user", &dev.user},
+		{"control", &dev.control},
+		{"bypass", &dev.bypass},
+	}
+	for _, bar := range bars {
+		barDevName := prefix + bar.name
+		if _, err := os.Stat(barDevName); os.IsNotExist(err) {
+			continue
+		}
+		if len(barSizes) == 0 {
+			dev.Close()
+			return nil, newPCIeError("map xdma BAR", barDevName,
+				ErrBARNotFound)
+		}
+		*bar.bar, err = pcieBARMapDev(barDevName, barSizes[0])
+		if err != nil {
+			dev.Close()
+			return nil, err
+		}
+		barSizes = barSizes[1:]
+	}
+
+	// open event devices
+	eventDevNames, err := xdmaGlobSorted(prefix + "events_")
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	for _, eventDevName := range eventDevNames {
+		event, err := PCIeEventOpen(eventDevName)
+		if err != nil {
+			dev.Close()
+			return nil, err
+		}
+		dev.events = append(dev.events, event)
+	}
+
+	return dev, nil
+}
+
+// xdmaGlobSorted returns the devices whose names consist of the prefix and a
+// number, sorted by the number.
+func xdmaGlobSorted(prefix string) ([]string, error) {
+	devNames, err := filepath.Glob(prefix + "[0-9]*")
+	if err != nil {
+		return nil, newPCIeError("find xdma devices", prefix, err)
+	}
+
+	numbers := make(map[string]int)
+	var sorted []string
+	for _, devName := range devNames {
+		number, err := strconv.Atoi(strings.TrimPrefix(devName, prefix))
+		if err != nil {
+			continue
+		}
+		numbers[devName] = number
+		sorted = append(sorted, devName)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return numbers[sorted[i]] < numbers[sorted[j]]
+	})
+	return sorted, nil
+}
+
+// pcieSysfsBARSizes returns the sizes of the memory BARs of the device with the
+// specified PCI address, in BAR order.
+func pcieSysfsBARSizes(devAddr string) ([]int, error) {
+	resourceFilename := filepath.Join(pcieSysfsDevicesDir, devAddr, "resource")
+	resourceFile, err := os.Open(resourceFilename)
+	if err != nil {
+		return nil, newPCIeError("open pci resource file", resourceFilename,
+			err)
+	}
+	defer resourceFile.Close()
+
+	// each line holds start address, end address and flags of a resource.
+	// the first six lines are the BARs
+	var sizes []int
+	scanner := bufio.NewScanner(resourceFile)
+	for barId := 0; barId < 6 && scanner.Scan(); barId++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			break
+		}
+		start, errStart := HexStringToInt(fields[0])
+		end, errEnd := HexStringToInt(fields[1])
+		flags, errFlags := HexStringToInt(fields[2])
+		if errStart != nil || errEnd != nil || errFlags != nil {
+			return nil, newPCIeError("parse pci resource file",
+				resourceFilename, ErrInvalidArgument)
+		}
+		if end == 0 || (flags&ioresourceMem) == 0 {
+			continue
+		}
+		sizes = append(sizes, int(end-start+1))
+	}
+	return sizes, nil
+}
+
+// pcieBARMapDev memory-maps a BAR through a character device of the DMA kernel
+// driver.
+func pcieBARMapDev(devName string, size int) (*PCIeBAR, error) {
+	fd, err := os.OpenFile(devName, os.O_RDWR|os.O_SYNC, 0666)
+	if err != nil {
+		return nil, newPCIeError("open device", devName, err)
+	}
+
+	bar, err := syscall.Mmap(int(fd.Fd()), 0, size,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		fd.Close()
+		return nil, newPCIeError("memory-map BAR", devName, err)
+	}
+
+	return newPCIeBAR(fd, devName, bar), nil
+}
+
+// Close closes all devices of the XDMA driver instance. Closing an already
+// closed instance has no effect.
+func (dev *XDMADevice) Close() error {
+	var err error
+	setErr := func(errClose error) {
+		if errClose != nil && err == nil {
+			err = errClose
+		}
+	}
+
+	for _, event := range dev.events {
+		setErr(event.Close())
+	}
+	for _, bar := range []*PCIeBAR{dev.user, dev.control, dev.bypass} {
+		if bar != nil {
+			setErr(bar.Close())
+		}
+	}
+	if dev.dma != nil {
+		setErr(dev.dma.Close())
+	}
+	return err
+}
+
+// Instance returns the number of the XDMA driver instance.
+func (dev *XDMADevice) Instance() int {
+	return dev.instance
+}
+
+// Addr returns the PCI address of the card.
+func (dev *XDMADevice) Addr() string {
+	return dev.devAddr
+}
+
+// DMA returns the DMA channels of the instance. It returns nil if the instance
+// does not provide any DMA channels.
+func (dev *XDMADevice) DMA() *PCIeDMAMultiChannel {
+	return dev.dma
+}
+
+// User returns the user BAR. It returns nil if the card does not provide a
+// user BAR.
+func (dev *XDMADevice) User() *PCIeBAR {
+	return dev.user
+}
+
+// Control returns the DMA engine configuration BAR.
+func (dev *XDMADevice) Control() *PCIeBAR {
+	return dev.control
+}
+
+// Bypass returns the DMA bypass BAR. It returns nil if the card does not
+// provide a bypass BAR.
+func (dev *XDMADevice) Bypass() *PCIeBAR {
+	return dev.bypass
+}
+
+// Events returns the user interrupt event devices, ordered by interrupt
+// number.
+func (dev *XDMADevice) Events() []*PCIeEvent {
+	return dev.events
+}